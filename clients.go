@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/mpdroog/radiusd/config"
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/radius"
+)
+
+// NAS table shared by all listeners
+var clients = radius.NewClients(nil)
+
+// (Re)load clients from config and nas-table
+func loadClients(storage model.Storage) error {
+	var list []*radius.Client
+	for name, c := range config.C.Clients {
+		for _, cidr := range c.CIDR {
+			client, e := radius.NewClient(name, cidr, c.Secret, c.Type)
+			if e != nil {
+				return fmt.Errorf("clients.%s: %s", name, e.Error())
+			}
+			client.RequireMessageAuthenticator = c.RequireMessageAuthenticator
+			list = append(list, client)
+		}
+	}
+
+	if config.C.NasTable {
+		nases, e := model.NASList(storage)
+		if e != nil {
+			return e
+		}
+		for _, nas := range nases {
+			client, e := radius.NewClient(nas.Name, nas.CIDR, nas.Secret, nas.Type)
+			if e != nil {
+				return fmt.Errorf("nas.%s: %s", nas.Name, e.Error())
+			}
+			client.RequireMessageAuthenticator = nas.RequireMessageAuthenticator
+			list = append(list, client)
+		}
+	}
+
+	clients.Replace(list)
+	if config.Verbose {
+		config.Log.Printf("Loaded %d clients", len(list))
+	}
+	return nil
+}
+
+// Secret to sign Disconnect/CoA-Requests towards nasIP
+func dynAuthSecret(nasIP string) string {
+	if nas := clients.Match(net.ParseIP(nasIP)); nas != nil {
+		return nas.Secret
+	}
+	return config.C.DynAuth.Secret
+}
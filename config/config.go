@@ -2,23 +2,89 @@ package config
 
 import (
 	"fmt"
+	"io"
 	"log"
-	"net"
 	"os"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
 type Listener struct {
-	Addr   string
-	Secret string
+	Addr      string
+	Type      string // auth or acct, defaults to the listen-name
+	Transport string // udp (default), tcp or tls
+	Cert      string // tls: Server certificate (PEM)
+	Key       string // tls: Server key (PEM)
+	CA        string // tls: CA to verify NAS certificates (PEM)
+	Secret    string
+	CIDR      []string
+	Workers   int           // Concurrent requests
+	QueueSize int           // Requests waiting for a worker
+	Timeout   time.Duration // Max time queued before handling, later is dropped
+	DupWindow time.Duration // Replay responses to retransmits for this long
+
+	IdleTimeout time.Duration // tcp/tls: Close connections without requests
+	MaxInFlight int           // tcp/tls: Concurrent requests per connection
+}
+
+// NAS with its own secret
+type Client struct {
 	CIDR   []string
+	Secret string
+	Type   string // NAS type (i.e. mikrotik)
+
+	RequireMessageAuthenticator bool // Drop Access-Requests without it
+}
+
+// Dynamic Authorization (RFC5176) towards the NAS
+type DynAuth struct {
+	Port    int
+	Secret  string
+	Timeout time.Duration
+}
+
+// EAP (RFC3579) for 802.1X
+type EAP struct {
+	Methods []string      // Preference order, i.e. mschapv2, md5
+	Name    string        // Server name sent in challenges
+	Timeout time.Duration // Forget conversations without reply
+	Cert    string        // peap/ttls/tls: Server certificate (PEM)
+	Key     string        // peap/ttls/tls: Server key (PEM)
+	CA      string        // tls: CA bundle to verify client certificates (PEM)
+	CRL     []string      // tls: Revocation lists (PEM or DER), reloaded on change
+}
+
+// Sessions of users whose block_remaining reached zero
+type Quota struct {
+	Action    string // disconnect, coa or empty to keep them running
+	RateLimit string // coa: MikrotikRateLimit to throttle to
+}
+
+// Remove sessions the NAS stopped reporting on
+type Reaper struct {
+	Interim  time.Duration // Acct-Interim-Interval of the NAS, 0 disables
+	Multiple int           // Missed interims before a session is stale
+	Every    time.Duration // Check interval
+}
+
+// Second factor for users with a TOTP secret
+type TOTP struct {
+	Timeout time.Duration // Time to enter the one-time code
 }
 
 type Conf struct {
+	Driver        string // mysql (default), postgres or sqlite
 	Dsn           string
 	Listen        map[string]Listener
 	ControlListen string
+	DynAuth       DynAuth
+	Quota         Quota
+	Reaper        Reaper
+	Clients       map[string]Client // Name => NAS
+	NasTable      bool              // Also load clients from the nas-table
+	EAP           EAP
+	TOTP          TOTP
 }
 
 var (
@@ -28,7 +94,7 @@ var (
 	Verbose  bool
 	Hostname string
 	Stopping bool
-	Sock     []*net.UDPConn
+	Sock     []io.Closer
 )
 
 func Init(path string) error {
@@ -42,6 +108,76 @@ func Init(path string) error {
 	if _, e := toml.DecodeReader(r, &C); e != nil {
 		return fmt.Errorf("TOML: %s", e)
 	}
+	if C.Driver == "" {
+		C.Driver = "mysql"
+	}
+	if C.Driver != "mysql" && C.Driver != "postgres" && C.Driver != "sqlite" {
+		return fmt.Errorf("Driver must be mysql, postgres or sqlite")
+	}
+	for name, l := range C.Listen {
+		if l.Type == "" {
+			l.Type = name
+		}
+		if l.Transport == "" {
+			l.Transport = "udp"
+		}
+		C.Listen[name] = l
+		if l.Type != "auth" && l.Type != "acct" {
+			return fmt.Errorf("listen.%s: Type must be auth or acct", name)
+		}
+		if l.Transport != "udp" && l.Transport != "tcp" && l.Transport != "tls" {
+			return fmt.Errorf("listen.%s: Transport must be udp, tcp or tls", name)
+		}
+		if l.Transport == "tls" && (l.Cert == "" || l.Key == "" || l.CA == "") {
+			return fmt.Errorf("listen.%s: Cert, Key and CA required for tls", name)
+		}
+	}
+	for name, c := range C.Clients {
+		if c.Secret == "" || len(c.CIDR) == 0 {
+			return fmt.Errorf("clients.%s: CIDR and Secret required", name)
+		}
+	}
+	if C.DynAuth.Port == 0 {
+		C.DynAuth.Port = 3799
+	}
+	if C.DynAuth.Timeout == 0 {
+		C.DynAuth.Timeout = 3 * time.Second
+	}
+	if C.Quota.Action != "" && C.Quota.Action != "disconnect" && C.Quota.Action != "coa" {
+		return fmt.Errorf("quota: Action must be disconnect or coa")
+	}
+	if C.Quota.Action == "coa" && C.Quota.RateLimit == "" {
+		return fmt.Errorf("quota: RateLimit required for coa")
+	}
+	if C.Reaper.Multiple == 0 {
+		C.Reaper.Multiple = 3
+	}
+	if C.Reaper.Every == 0 {
+		C.Reaper.Every = time.Minute
+	}
+	if len(C.EAP.Methods) == 0 {
+		C.EAP.Methods = []string{"mschapv2", "md5"}
+	}
+	for _, method := range C.EAP.Methods {
+		if (method == "peap" || method == "ttls" || method == "tls") && (C.EAP.Cert == "" || C.EAP.Key == "") {
+			return fmt.Errorf("eap: Cert and Key required for %s", method)
+		}
+		if method == "tls" && C.EAP.CA == "" {
+			return fmt.Errorf("eap: CA required for tls")
+		}
+	}
+	if C.EAP.CA != "" && (C.EAP.Cert == "" || C.EAP.Key == "") {
+		return fmt.Errorf("eap: Cert and Key required with CA")
+	}
+	if C.EAP.Name == "" {
+		C.EAP.Name = "radiusd"
+	}
+	if C.EAP.Timeout == 0 {
+		C.EAP.Timeout = 30 * time.Second
+	}
+	if C.TOTP.Timeout == 0 {
+		C.TOTP.Timeout = time.Minute
+	}
 	Hostname, e = os.Hostname()
 	if e != nil {
 		panic(e)
@@ -7,6 +7,9 @@ import (
 	"net/http"
 
 	"github.com/mpdroog/radiusd/config"
+	"github.com/mpdroog/radiusd/dynauth"
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/radius"
 	"github.com/itshosted/webutils/httpd"
 	"github.com/itshosted/webutils/middleware"
 	"github.com/itshosted/webutils/muxdoc"
@@ -14,16 +17,21 @@ import (
 )
 
 var (
-	mux muxdoc.MuxDoc
-	ln  net.Listener
+	mux   muxdoc.MuxDoc
+	ln    net.Listener
+	store model.Storage
 )
 
-func Control() {
+func Control(storage model.Storage) {
+	store = storage
 	mux.Title = "RadiusdD API"
 	mux.Desc = "Administrative API"
 	mux.Add("/", doc, "This documentation")
 	mux.Add("/shutdown", shutdown, "Finish jobs and close application")
 	mux.Add("/verbose", verbose, "Toggle verbosity-mode")
+	mux.Add("/stats", stats, "Request/error counters (total and per client)")
+	mux.Add("/clients/reload", reloadClients, "Reload NAS clients from config and nas-table")
+	mux.Add("/disconnect", disconnect, "Disconnect session (?user=&session_id=&nas_ip=) with RFC5176 Disconnect-Request")
 
 	middleware.Add(ratelimit.Use(5, 5))
 	http.Handle("/", middleware.Use(mux.Mux))
@@ -99,3 +107,65 @@ func verbose(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// Counters as collected by the listeners
+func stats(w http.ResponseWriter, r *http.Request) {
+	auth, acct := radius.Statistics()
+	out := struct {
+		Auth    radius.Stats
+		Acct    radius.Stats
+		Clients map[string]radius.Stats
+	}{auth, acct, radius.ClientStatistics()}
+
+	if e := httpd.FlushJson(w, out); e != nil {
+		config.Log.Printf("control: " + e.Error())
+	}
+}
+
+// Re-read the nas-table
+func reloadClients(w http.ResponseWriter, r *http.Request) {
+	if e := loadClients(store); e != nil {
+		httpd.Error(w, e, "Failed loading clients")
+		return
+	}
+	if e := httpd.FlushJson(w, httpd.Reply(true, "Reloaded clients.")); e != nil {
+		config.Log.Printf("control: " + e.Error())
+	}
+}
+
+// Send Disconnect-Request to the NAS owning the session
+func disconnect(w http.ResponseWriter, r *http.Request) {
+	user := r.FormValue("user")
+	sessID := r.FormValue("session_id")
+	nasIP := r.FormValue("nas_ip")
+	if user == "" || sessID == "" || nasIP == "" {
+		w.WriteHeader(400)
+		if e := httpd.FlushJson(w, httpd.Reply(false, "Missing user, session_id or nas_ip")); e != nil {
+			config.Log.Printf("control: " + e.Error())
+		}
+		return
+	}
+
+	sess, e := model.SessionGet(store, sessID, user, nasIP)
+	if e == model.ErrNoRows {
+		w.WriteHeader(404)
+		if e := httpd.FlushJson(w, httpd.Reply(false, "No such session")); e != nil {
+			config.Log.Printf("control: " + e.Error())
+		}
+		return
+	}
+	if e != nil {
+		httpd.Error(w, e, "Failed reading session")
+		return
+	}
+
+	c := config.C.DynAuth
+	if e := dynauth.Disconnect(c.Port, dynAuthSecret(sess.NasIP), sess, c.Timeout, config.Verbose, config.Log); e != nil {
+		config.Log.Printf("control: " + e.Error())
+		httpd.Error(w, nil, e.Error())
+		return
+	}
+	if e := httpd.FlushJson(w, httpd.Reply(true, "Disconnected.")); e != nil {
+		config.Log.Printf("control: " + e.Error())
+	}
+}
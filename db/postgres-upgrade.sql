@@ -0,0 +1,80 @@
+/*
+ PostgreSQL equivalent of upgrade.sql for a database created before
+ the nas, quota_action and reaper changes of postgres.sql, run once
+ before starting the new version on any node. Safe to run twice.
+
+ Target Server Type    : PostgreSQL
+ Target Server Version : 9.6
+ File Encoding         : utf-8
+*/
+
+-- ----------------------------
+--  Packet counters above 2^32
+-- ----------------------------
+ALTER TABLE "accounting"
+  ALTER COLUMN "packets_in" TYPE bigint,
+  ALTER COLUMN "packets_out" TYPE bigint;
+
+-- ----------------------------
+--  RADIUS clients with their own secret
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS "nas" (
+  "id" serial PRIMARY KEY,
+  "name" varchar(50) NOT NULL,
+  "cidr" varchar(50) NOT NULL,
+  "secret" varchar(255) NOT NULL,
+  "type" varchar(20) NOT NULL DEFAULT 'other',
+  "require_ma" boolean NOT NULL DEFAULT false,
+  CONSTRAINT "nas_unique_name" UNIQUE ("name"),
+  CONSTRAINT "nas_unique_cidr" UNIQUE ("cidr")
+);
+
+-- ----------------------------
+--  Product limits, NULL sends nothing
+-- ----------------------------
+ALTER TABLE "product"
+  ADD COLUMN IF NOT EXISTS "interim_interval" integer DEFAULT NULL CHECK ("interim_interval" >= 0),
+  ADD COLUMN IF NOT EXISTS "session_timeout" integer DEFAULT NULL CHECK ("session_timeout" >= 0),
+  ADD COLUMN IF NOT EXISTS "idle_timeout" integer DEFAULT NULL CHECK ("idle_timeout" >= 0);
+
+-- ----------------------------
+--  Quota enforcement log
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS "quota_action" (
+  "id" serial PRIMARY KEY,
+  "user" varchar(100) NOT NULL REFERENCES "user" ("user"),
+  "session_id" varchar(20) NOT NULL,
+  "nas_ip" varchar(50) NOT NULL,
+  "action" varchar(10) NOT NULL CHECK ("action" IN ('disconnect', 'coa')),
+  "error" varchar(255) NOT NULL DEFAULT '',
+  "hostname" varchar(50) NOT NULL,
+  "time_added" bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS "fk_quota_action_user" ON "quota_action" ("user");
+
+-- ----------------------------
+--  Reaper, open sessions count as seen now
+-- ----------------------------
+ALTER TABLE "session"
+  ADD COLUMN IF NOT EXISTS "time_updated" bigint NOT NULL DEFAULT 0;
+UPDATE "session" SET "time_updated" = EXTRACT(EPOCH FROM now())::bigint
+  WHERE "time_updated" = 0;
+CREATE INDEX IF NOT EXISTS "idx_session_updated" ON "session" ("time_updated");
+
+ALTER TABLE "session_log"
+  ADD COLUMN IF NOT EXISTS "terminate_cause" integer DEFAULT NULL CHECK ("terminate_cause" >= 0);
+
+-- ----------------------------
+--  Two-factor login
+-- ----------------------------
+ALTER TABLE "user"
+  ADD COLUMN IF NOT EXISTS "totp_secret" varchar(64) DEFAULT NULL,
+  ADD COLUMN IF NOT EXISTS "totp_counter" bigint DEFAULT NULL CHECK ("totp_counter" >= 0);
+
+CREATE TABLE IF NOT EXISTS "totp_challenge" (
+  "state" varchar(32) PRIMARY KEY,
+  "user" varchar(100) NOT NULL REFERENCES "user" ("user"),
+  "time_added" bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS "fk_totp_challenge_user" ON "totp_challenge" ("user");
+CREATE INDEX IF NOT EXISTS "idx_totp_challenge_added" ON "totp_challenge" ("time_added");
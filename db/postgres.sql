@@ -0,0 +1,205 @@
+/*
+ PostgreSQL schema, equivalent of vpnxs_radius.sql
+
+ Target Server Type    : PostgreSQL
+ Target Server Version : 9.5
+ File Encoding         : utf-8
+*/
+
+-- ----------------------------
+--  Table structure for "dns"
+-- ----------------------------
+DROP TABLE IF EXISTS "dns" CASCADE;
+CREATE TABLE "dns" (
+  "id" serial PRIMARY KEY,
+  "name" varchar(10) NOT NULL,
+  "one" varchar(50) NOT NULL,
+  "two" varchar(50) NOT NULL,
+  CONSTRAINT "dns_unique_name" UNIQUE ("name"),
+  CONSTRAINT "dns_unique_dns" UNIQUE ("one")
+);
+
+-- ----------------------------
+--  Table structure for "nas"
+-- ----------------------------
+DROP TABLE IF EXISTS "nas" CASCADE;
+CREATE TABLE "nas" (
+  "id" serial PRIMARY KEY,
+  "name" varchar(50) NOT NULL,
+  "cidr" varchar(50) NOT NULL,
+  "secret" varchar(255) NOT NULL,
+  "type" varchar(20) NOT NULL DEFAULT 'other',
+  "require_ma" boolean NOT NULL DEFAULT false,
+  CONSTRAINT "nas_unique_name" UNIQUE ("name"),
+  CONSTRAINT "nas_unique_cidr" UNIQUE ("cidr")
+);
+COMMENT ON TABLE "nas" IS 'RADIUS clients.';
+COMMENT ON COLUMN "nas"."name" IS 'Short name used in logs';
+COMMENT ON COLUMN "nas"."cidr" IS 'IP/prefix, longest prefix wins';
+COMMENT ON COLUMN "nas"."type" IS 'i.e. mikrotik';
+COMMENT ON COLUMN "nas"."require_ma" IS 'Drop Access-Requests without Message-Authenticator';
+
+-- ----------------------------
+--  Table structure for "product"
+-- ----------------------------
+DROP TABLE IF EXISTS "product" CASCADE;
+CREATE TABLE "product" (
+  "id" serial PRIMARY KEY,
+  "product" varchar(50) NOT NULL,
+  "simultaneous_use" integer NOT NULL CHECK ("simultaneous_use" >= 0),
+  "ratelimit_up" integer DEFAULT NULL CHECK ("ratelimit_up" >= 0),
+  "ratelimit_down" integer DEFAULT NULL CHECK ("ratelimit_down" >= 0),
+  "ratelimit_unit" varchar(1) DEFAULT NULL CHECK ("ratelimit_unit" IN ('k', 'M')),
+  "interim_interval" integer DEFAULT NULL CHECK ("interim_interval" >= 0),
+  "session_timeout" integer DEFAULT NULL CHECK ("session_timeout" >= 0),
+  "idle_timeout" integer DEFAULT NULL CHECK ("idle_timeout" >= 0),
+  CONSTRAINT "product_unique_product" UNIQUE ("product")
+);
+COMMENT ON COLUMN "product"."simultaneous_use" IS 'Max sessions';
+COMMENT ON COLUMN "product"."interim_interval" IS 'Acct-Interim-Interval in sec';
+COMMENT ON COLUMN "product"."session_timeout" IS 'Max session length in sec';
+COMMENT ON COLUMN "product"."idle_timeout" IS 'Disconnect after idle sec';
+
+-- ----------------------------
+--  Table structure for "user"
+-- ----------------------------
+DROP TABLE IF EXISTS "user" CASCADE;
+CREATE TABLE "user" (
+  "id" serial PRIMARY KEY,
+  "user" varchar(100) NOT NULL,
+  "pass" varchar(255) NOT NULL,
+  "block_remaining" bigint DEFAULT NULL CHECK ("block_remaining" >= 0),
+  "active_until" date DEFAULT NULL,
+  "dedicated_ip" varchar(50) DEFAULT NULL,
+  "product_id" integer NOT NULL REFERENCES "product" ("id"),
+  "dns_id" integer DEFAULT NULL REFERENCES "dns" ("id"),
+  "time_added" bigint NOT NULL,
+  "time_updated" bigint DEFAULT NULL,
+  "totp_secret" varchar(64) DEFAULT NULL,
+  "totp_counter" bigint DEFAULT NULL CHECK ("totp_counter" >= 0),
+  CONSTRAINT "user_unique_login" UNIQUE ("user"),
+  CONSTRAINT "user_unique_ip" UNIQUE ("dedicated_ip")
+);
+CREATE INDEX "fk_user_product" ON "user" ("product_id");
+CREATE INDEX "fk_user_dns_1" ON "user" ("dns_id");
+COMMENT ON COLUMN "user"."block_remaining" IS 'Octets left, clamped at 0 by pgUpdateUsage.sql';
+COMMENT ON COLUMN "user"."active_until" IS 'Account becomes inactive on given date';
+COMMENT ON COLUMN "user"."dedicated_ip" IS 'Static IP';
+COMMENT ON COLUMN "user"."dns_id" IS 'DNS Pri+Sec';
+COMMENT ON COLUMN "user"."totp_secret" IS 'Base32 TOTP secret, enables two-factor login';
+COMMENT ON COLUMN "user"."totp_counter" IS 'Last accepted TOTP counter';
+
+-- ----------------------------
+--  Table structure for "accounting"
+-- ----------------------------
+DROP TABLE IF EXISTS "accounting" CASCADE;
+CREATE TABLE "accounting" (
+  "user" varchar(100) NOT NULL REFERENCES "user" ("user"),
+  "date" varchar(16) NOT NULL DEFAULT '',
+  "hostname" varchar(50) NOT NULL,
+  "bytes_in" bigint NOT NULL CHECK ("bytes_in" >= 0),
+  "bytes_out" bigint NOT NULL CHECK ("bytes_out" >= 0),
+  "packets_in" bigint NOT NULL CHECK ("packets_in" >= 0),
+  "packets_out" bigint NOT NULL CHECK ("packets_out" >= 0),
+  PRIMARY KEY ("user", "date", "hostname")
+);
+COMMENT ON COLUMN "accounting"."date" IS '1min consolidated YYYY-MM-DD HH:MM';
+COMMENT ON COLUMN "accounting"."hostname" IS 'RadiusD-server for unique key';
+
+-- ----------------------------
+--  Table structure for "dedi_ip"
+-- ----------------------------
+DROP TABLE IF EXISTS "dedi_ip" CASCADE;
+CREATE TABLE "dedi_ip" (
+  "id" serial PRIMARY KEY,
+  "user_id" integer DEFAULT NULL,
+  "ip" varchar(50) NOT NULL,
+  "time_added" bigint NOT NULL,
+  "time_reserved" bigint DEFAULT NULL,
+  "time_updated" bigint NOT NULL,
+  CONSTRAINT "dedi_ip_unique_ip" UNIQUE ("ip"),
+  CONSTRAINT "dedi_ip_unique_user" UNIQUE ("user_id")
+);
+
+-- ----------------------------
+--  Table structure for "quota_action"
+-- ----------------------------
+DROP TABLE IF EXISTS "quota_action" CASCADE;
+CREATE TABLE "quota_action" (
+  "id" serial PRIMARY KEY,
+  "user" varchar(100) NOT NULL REFERENCES "user" ("user"),
+  "session_id" varchar(20) NOT NULL,
+  "nas_ip" varchar(50) NOT NULL,
+  "action" varchar(10) NOT NULL CHECK ("action" IN ('disconnect', 'coa')),
+  "error" varchar(255) NOT NULL DEFAULT '',
+  "hostname" varchar(50) NOT NULL,
+  "time_added" bigint NOT NULL
+);
+CREATE INDEX "fk_quota_action_user" ON "quota_action" ("user");
+COMMENT ON TABLE "quota_action" IS 'Sessions disconnected/throttled on empty block.';
+COMMENT ON COLUMN "quota_action"."error" IS 'Empty if NAS acknowledged';
+COMMENT ON COLUMN "quota_action"."hostname" IS 'RadiusD-server that sent it';
+
+-- ----------------------------
+--  Table structure for "session"
+-- ----------------------------
+DROP TABLE IF EXISTS "session" CASCADE;
+CREATE TABLE "session" (
+  "session_id" varchar(20) NOT NULL,
+  "user" varchar(100) NOT NULL REFERENCES "user" ("user"),
+  "nas_ip" varchar(50) NOT NULL,
+  "bytes_in" bigint NOT NULL CHECK ("bytes_in" >= 0),
+  "bytes_out" bigint NOT NULL CHECK ("bytes_out" >= 0),
+  "packets_in" bigint NOT NULL CHECK ("packets_in" >= 0),
+  "packets_out" bigint NOT NULL CHECK ("packets_out" >= 0),
+  "session_time" bigint NOT NULL CHECK ("session_time" >= 0),
+  "client_ip" varchar(50) NOT NULL,
+  "assigned_ip" varchar(50) NOT NULL,
+  "time_added" bigint NOT NULL,
+  "time_updated" bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY ("session_id", "user", "nas_ip")
+);
+CREATE INDEX "fk_session_user" ON "session" ("user");
+CREATE INDEX "idx_session_updated" ON "session" ("time_updated");
+COMMENT ON TABLE "session" IS 'Active connections.';
+COMMENT ON COLUMN "session"."nas_ip" IS 'VPN Server';
+COMMENT ON COLUMN "session"."session_time" IS 'Session open in sec';
+COMMENT ON COLUMN "session"."time_updated" IS 'Last Start/Interim-Update';
+
+-- ----------------------------
+--  Table structure for "session_log"
+-- ----------------------------
+DROP TABLE IF EXISTS "session_log" CASCADE;
+CREATE TABLE "session_log" (
+  "id" serial PRIMARY KEY,
+  "bytes_in" bigint NOT NULL CHECK ("bytes_in" >= 0),
+  "bytes_out" bigint NOT NULL CHECK ("bytes_out" >= 0),
+  "packets_in" bigint NOT NULL CHECK ("packets_in" >= 0),
+  "packets_out" bigint NOT NULL CHECK ("packets_out" >= 0),
+  "session_id" varchar(20) NOT NULL,
+  "session_time" bigint NOT NULL CHECK ("session_time" >= 0),
+  "user" varchar(100) NOT NULL REFERENCES "user" ("user"),
+  "nas_ip" varchar(50) NOT NULL,
+  "client_ip" varchar(50) NOT NULL,
+  "assigned_ip" varchar(50) NOT NULL,
+  "time_added" bigint NOT NULL,
+  "terminate_cause" integer DEFAULT NULL CHECK ("terminate_cause" >= 0)
+);
+CREATE INDEX "fk_session_log_user" ON "session_log" ("user");
+COMMENT ON TABLE "session_log" IS 'Closed connections.';
+COMMENT ON COLUMN "session_log"."session_time" IS 'Session open in sec';
+COMMENT ON COLUMN "session_log"."terminate_cause" IS 'Acct-Terminate-Cause if closed by us';
+
+-- ----------------------------
+--  Table structure for "totp_challenge"
+-- ----------------------------
+DROP TABLE IF EXISTS "totp_challenge" CASCADE;
+CREATE TABLE "totp_challenge" (
+  "state" varchar(32) PRIMARY KEY,
+  "user" varchar(100) NOT NULL REFERENCES "user" ("user"),
+  "time_added" bigint NOT NULL
+);
+CREATE INDEX "fk_totp_challenge_user" ON "totp_challenge" ("user");
+CREATE INDEX "idx_totp_challenge_added" ON "totp_challenge" ("time_added");
+COMMENT ON TABLE "totp_challenge" IS 'Pending one-time code prompts.';
+COMMENT ON COLUMN "totp_challenge"."state" IS 'RADIUS State (hex)';
@@ -0,0 +1,85 @@
+/*
+ Upgrade a database created with an earlier vpnxs_radius.sql, run
+ once before starting the new version on any node.
+
+ Target Server Type    : MySQL
+ Target Server Version : 50505
+ File Encoding         : utf-8
+*/
+
+SET NAMES utf8;
+
+-- ----------------------------
+--  Packet counters above 2^32
+-- ----------------------------
+ALTER TABLE `accounting`
+  MODIFY `packets_in` bigint(15) unsigned NOT NULL,
+  MODIFY `packets_out` bigint(15) unsigned NOT NULL;
+
+-- ----------------------------
+--  RADIUS clients with their own secret
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `nas` (
+  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(50) NOT NULL COMMENT 'Short name used in logs',
+  `cidr` varchar(50) NOT NULL COMMENT 'IP/prefix, longest prefix wins',
+  `secret` varchar(255) NOT NULL,
+  `type` varchar(20) NOT NULL DEFAULT 'other' COMMENT 'i.e. mikrotik',
+  `require_ma` tinyint(1) unsigned NOT NULL DEFAULT '0' COMMENT 'Drop Access-Requests without Message-Authenticator',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `unique_name` (`name`),
+  UNIQUE KEY `unique_cidr` (`cidr`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='RADIUS clients.';
+
+-- ----------------------------
+--  Product limits, NULL sends nothing
+-- ----------------------------
+ALTER TABLE `product`
+  ADD COLUMN `interim_interval` int(10) unsigned DEFAULT NULL COMMENT 'Acct-Interim-Interval in sec',
+  ADD COLUMN `session_timeout` int(10) unsigned DEFAULT NULL COMMENT 'Max session length in sec',
+  ADD COLUMN `idle_timeout` int(10) unsigned DEFAULT NULL COMMENT 'Disconnect after idle sec';
+
+-- ----------------------------
+--  Quota enforcement log
+-- ----------------------------
+CREATE TABLE IF NOT EXISTS `quota_action` (
+  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `user` varchar(100) NOT NULL,
+  `session_id` varchar(20) NOT NULL,
+  `nas_ip` varchar(50) NOT NULL,
+  `action` enum('disconnect','coa') NOT NULL,
+  `error` varchar(255) NOT NULL DEFAULT '' COMMENT 'Empty if NAS acknowledged',
+  `hostname` varchar(50) NOT NULL COMMENT 'RadiusD-server that sent it',
+  `time_added` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `fk_quota_action_user` (`user`),
+  CONSTRAINT `fk_quota_action_user` FOREIGN KEY (`user`) REFERENCES `user` (`user`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Sessions disconnected/throttled on empty block.';
+
+-- ----------------------------
+--  Reaper, open sessions count as seen now
+-- ----------------------------
+ALTER TABLE `session`
+  ADD COLUMN `time_updated` int(10) unsigned NOT NULL DEFAULT '0' COMMENT 'Last Start/Interim-Update',
+  ADD KEY `idx_session_updated` (`time_updated`);
+UPDATE `session` SET `time_updated` = UNIX_TIMESTAMP();
+
+ALTER TABLE `session_log`
+  ADD COLUMN `terminate_cause` int(10) unsigned DEFAULT NULL COMMENT 'Acct-Terminate-Cause if closed by us';
+
+-- ----------------------------
+--  Two-factor login
+-- ----------------------------
+ALTER TABLE `user`
+  ADD COLUMN `totp_secret` varchar(64) DEFAULT NULL COMMENT 'Base32 TOTP secret, enables two-factor login',
+  ADD COLUMN `totp_counter` bigint(20) unsigned DEFAULT NULL COMMENT 'Last accepted TOTP counter';
+
+CREATE TABLE IF NOT EXISTS `totp_challenge` (
+  `state` varchar(32) NOT NULL COMMENT 'RADIUS State (hex)',
+  `user` varchar(100) NOT NULL,
+  `time_added` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`state`),
+  KEY `fk_totp_challenge_user` (`user`),
+  KEY `idx_totp_challenge_added` (`time_added`),
+  CONSTRAINT `fk_totp_challenge_user` FOREIGN KEY (`user`) REFERENCES `user` (`user`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Pending one-time code prompts.';
@@ -27,8 +27,8 @@ CREATE TABLE `accounting` (
   `hostname` varchar(50) NOT NULL COMMENT 'RadiusD-server for unique key',
   `bytes_in` bigint(15) unsigned NOT NULL COMMENT 'Octet in',
   `bytes_out` bigint(15) unsigned NOT NULL COMMENT 'Octet out',
-  `packets_in` int(10) unsigned NOT NULL,
-  `packets_out` int(10) unsigned NOT NULL,
+  `packets_in` bigint(15) unsigned NOT NULL,
+  `packets_out` bigint(15) unsigned NOT NULL,
   PRIMARY KEY (`user`,`date`,`hostname`),
   CONSTRAINT `fk_accounting_user` FOREIGN KEY (`user`) REFERENCES `user` (`user`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -63,6 +63,22 @@ CREATE TABLE `dns` (
   UNIQUE KEY `unique_dns` (`one`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+-- ----------------------------
+--  Table structure for `nas`
+-- ----------------------------
+DROP TABLE IF EXISTS `nas`;
+CREATE TABLE `nas` (
+  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(50) NOT NULL COMMENT 'Short name used in logs',
+  `cidr` varchar(50) NOT NULL COMMENT 'IP/prefix, longest prefix wins',
+  `secret` varchar(255) NOT NULL,
+  `type` varchar(20) NOT NULL DEFAULT 'other' COMMENT 'i.e. mikrotik',
+  `require_ma` tinyint(1) unsigned NOT NULL DEFAULT '0' COMMENT 'Drop Access-Requests without Message-Authenticator',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `unique_name` (`name`),
+  UNIQUE KEY `unique_cidr` (`cidr`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='RADIUS clients.';
+
 -- ----------------------------
 --  Table structure for `product`
 -- ----------------------------
@@ -74,10 +90,31 @@ CREATE TABLE `product` (
   `ratelimit_up` int(10) unsigned DEFAULT NULL,
   `ratelimit_down` int(10) unsigned DEFAULT NULL,
   `ratelimit_unit` enum('k','M') DEFAULT NULL,
+  `interim_interval` int(10) unsigned DEFAULT NULL COMMENT 'Acct-Interim-Interval in sec',
+  `session_timeout` int(10) unsigned DEFAULT NULL COMMENT 'Max session length in sec',
+  `idle_timeout` int(10) unsigned DEFAULT NULL COMMENT 'Disconnect after idle sec',
   PRIMARY KEY (`id`),
   UNIQUE KEY `unique_product` (`product`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+-- ----------------------------
+--  Table structure for `quota_action`
+-- ----------------------------
+DROP TABLE IF EXISTS `quota_action`;
+CREATE TABLE `quota_action` (
+  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,
+  `user` varchar(100) NOT NULL,
+  `session_id` varchar(20) NOT NULL,
+  `nas_ip` varchar(50) NOT NULL,
+  `action` enum('disconnect','coa') NOT NULL,
+  `error` varchar(255) NOT NULL DEFAULT '' COMMENT 'Empty if NAS acknowledged',
+  `hostname` varchar(50) NOT NULL COMMENT 'RadiusD-server that sent it',
+  `time_added` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `fk_quota_action_user` (`user`),
+  CONSTRAINT `fk_quota_action_user` FOREIGN KEY (`user`) REFERENCES `user` (`user`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Sessions disconnected/throttled on empty block.';
+
 -- ----------------------------
 --  Table structure for `session`
 -- ----------------------------
@@ -94,8 +131,10 @@ CREATE TABLE `session` (
   `client_ip` varchar(50) NOT NULL,
   `assigned_ip` varchar(50) NOT NULL,
   `time_added` int(10) unsigned NOT NULL,
+  `time_updated` int(10) unsigned NOT NULL DEFAULT '0' COMMENT 'Last Start/Interim-Update',
   PRIMARY KEY (`session_id`,`user`,`nas_ip`),
   KEY `fk_session_user` (`user`),
+  KEY `idx_session_updated` (`time_updated`),
   CONSTRAINT `fk_session_user` FOREIGN KEY (`user`) REFERENCES `user` (`user`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Active connections.';
 
@@ -116,11 +155,26 @@ CREATE TABLE `session_log` (
   `client_ip` varchar(50) NOT NULL,
   `assigned_ip` varchar(50) NOT NULL,
   `time_added` int(10) unsigned NOT NULL,
+  `terminate_cause` int(10) unsigned DEFAULT NULL COMMENT 'Acct-Terminate-Cause if closed by us',
   PRIMARY KEY (`id`),
   KEY `fk_session_log_user` (`user`),
   CONSTRAINT `fk_session_log_user` FOREIGN KEY (`user`) REFERENCES `user` (`user`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Closed connections.';
 
+-- ----------------------------
+--  Table structure for `totp_challenge`
+-- ----------------------------
+DROP TABLE IF EXISTS `totp_challenge`;
+CREATE TABLE `totp_challenge` (
+  `state` varchar(32) NOT NULL COMMENT 'RADIUS State (hex)',
+  `user` varchar(100) NOT NULL,
+  `time_added` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`state`),
+  KEY `fk_totp_challenge_user` (`user`),
+  KEY `idx_totp_challenge_added` (`time_added`),
+  CONSTRAINT `fk_totp_challenge_user` FOREIGN KEY (`user`) REFERENCES `user` (`user`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Pending one-time code prompts.';
+
 -- ----------------------------
 --  Table structure for `user`
 -- ----------------------------
@@ -136,6 +190,8 @@ CREATE TABLE `user` (
   `dns_id` int(3) unsigned DEFAULT NULL COMMENT 'DNS Pri+Sec',
   `time_added` int(10) unsigned NOT NULL,
   `time_updated` int(10) unsigned DEFAULT NULL,
+  `totp_secret` varchar(64) DEFAULT NULL COMMENT 'Base32 TOTP secret, enables two-factor login',
+  `totp_counter` bigint(20) unsigned DEFAULT NULL COMMENT 'Last accepted TOTP counter',
   PRIMARY KEY (`id`),
   UNIQUE KEY `unique_login` (`user`),
   UNIQUE KEY `unique_ip` (`dedicated_ip`),
@@ -0,0 +1,88 @@
+// Dynamic Authorization to push changes to a NAS
+// https://tools.ietf.org/html/rfc5176
+package dynauth
+
+import (
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrNAK        = errors.New("nas.nak")
+	ErrUnexpected = errors.New("nas.unexpected reply")
+)
+
+type codes struct {
+	Request radius.PacketCode
+	ACK     radius.PacketCode
+	NAK     radius.PacketCode
+}
+
+var (
+	disconnect = codes{radius.DisconnectRequest, radius.DisconnectACK, radius.DisconnectNAK}
+	coa        = codes{radius.CoARequest, radius.CoAACK, radius.CoANAK}
+)
+
+// Attributes identifying the session on the NAS
+func identify(sess model.Session) []radius.AttrEncoder {
+	attrs := []radius.AttrEncoder{
+		radius.NewAttr(radius.UserName, []byte(sess.User), 0),
+		radius.NewAttr(radius.AcctSessionId, []byte(sess.SessionID), 0),
+	}
+	if ip := net.ParseIP(sess.NasIP).To4(); ip != nil {
+		attrs = append(attrs, radius.NewAttr(radius.NASIPAddress, ip, 0))
+	}
+	if ip := net.ParseIP(sess.AssignedIP).To4(); ip != nil {
+		attrs = append(attrs, radius.NewAttr(radius.FramedIPAddress, ip, 0))
+	}
+	return attrs
+}
+
+func exchange(code codes, port int, secret string, sess model.Session, attrs []radius.AttrEncoder, timeout time.Duration, verbose bool, logger *log.Logger) error {
+	addr := net.JoinHostPort(sess.NasIP, strconv.Itoa(port))
+	req := radius.NewRequest(code.Request, secret, append(identify(sess), attrs...))
+
+	res, e := radius.Exchange(addr, req, timeout, verbose, logger)
+	if e != nil {
+		return e
+	}
+	switch res.Code {
+	case code.ACK:
+		return nil
+	case code.NAK:
+		cause := uint32(0)
+		if res.HasAttr(radius.ErrorCause) {
+			cause = radius.DecodeFour(res.Attr(radius.ErrorCause))
+		}
+		return errors.Wrapf(ErrNAK, "code=%s error-cause=%d", res.Code, cause)
+	}
+	return errors.Wrapf(ErrUnexpected, "code=%s", res.Code)
+}
+
+// Ask NAS to terminate the session
+func Disconnect(port int, secret string, sess model.Session, timeout time.Duration, verbose bool, logger *log.Logger) error {
+	if verbose {
+		logger.Printf("dynauth.disconnect sess=%s for user=%s on nasIP=%s", sess.SessionID, sess.User, sess.NasIP)
+	}
+	if e := exchange(disconnect, port, secret, sess, nil, timeout, verbose, logger); e != nil {
+		return errors.Wrapf(e, "dynauth.disconnect sess=%s", sess.SessionID)
+	}
+	return nil
+}
+
+// Ask NAS to change the session with given attributes
+func CoA(port int, secret string, sess model.Session, attrs []radius.AttrEncoder, timeout time.Duration, verbose bool, logger *log.Logger) error {
+	if verbose {
+		logger.Printf("dynauth.coa sess=%s for user=%s on nasIP=%s", sess.SessionID, sess.User, sess.NasIP)
+	}
+	if e := exchange(coa, port, secret, sess, attrs, timeout, verbose, logger); e != nil {
+		return errors.Wrapf(e, "dynauth.coa sess=%s", sess.SessionID)
+	}
+	return nil
+}
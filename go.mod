@@ -6,6 +6,8 @@ require (
 	github.com/BurntSushi/toml v1.3.2
 	github.com/go-sql-driver/mysql v1.8.1
 	github.com/itshosted/webutils v0.0.0-20230120094721-d656b0c12463
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/pkg/errors v0.9.1
 	golang.org/x/crypto v0.23.0
 )
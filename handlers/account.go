@@ -10,8 +10,8 @@ import (
 
 func createSess(req *radius.Packet) model.Session {
 	return model.Session{
-		BytesIn:     radius.DecodeFour(req.Attr(radius.AcctInputOctets)),
-		BytesOut:    radius.DecodeFour(req.Attr(radius.AcctOutputOctets)),
+		BytesIn:     radius.DecodeCounter(req, radius.AcctInputOctets, radius.AcctInputGigawords),
+		BytesOut:    radius.DecodeCounter(req, radius.AcctOutputOctets, radius.AcctOutputGigawords),
 		PacketsIn:   radius.DecodeFour(req.Attr(radius.AcctInputPackets)),
 		PacketsOut:  radius.DecodeFour(req.Attr(radius.AcctOutputPackets)),
 		SessionID:   string(req.Attr(radius.AcctSessionId)),
@@ -72,40 +72,57 @@ func (h *Handler) AcctUpdate(w io.Writer, req *radius.Packet) {
 		)
 	}
 
-	if e := model.SessionUpdate(h.Storage, sess); e != nil {
+	delta, e := model.SessionUpdate(h.Storage, sess)
+	if e != nil {
 		h.Logger.Printf("acct.update e=" + e.Error())
 		return
 	}
-	queue.Queue(sess.User, sess.BytesIn, sess.BytesOut, sess.PacketsIn, sess.PacketsOut)
+	queue.Queue(sess.User, delta.BytesIn, delta.BytesOut, uint64(delta.PacketsIn), uint64(delta.PacketsOut))
 
 	w.Write(radius.DefaultPacket(req, radius.AccountingResponse, "Updated accounting.", h.Verbose, h.Logger))
 }
 
-func (h *Handler) AcctStop(w io.Writer, req *radius.Packet) {
-	if e := radius.ValidateAcctRequest(req); e != "" {
-		h.Logger.Printf("acct.stop e=" + e)
+// Accounting-On/Off: the NAS (re)started or shuts down, none of its
+// sessions are running anymore
+func (h *Handler) AcctOnOff(w io.Writer, req *radius.Packet) {
+	if e := radius.ValidateAcctOnOff(req); e != "" {
+		h.Logger.Printf("acct.onoff e=" + e)
 		return
 	}
-	user := string(req.Attr(radius.UserName))
-	sess := string(req.Attr(radius.AcctSessionId))
 	nasIp := radius.DecodeIP(req.Attr(radius.NASIPAddress)).String()
+	status := radius.DecodeFour(req.Attr(radius.AcctStatusType))
 
-	sessTime := radius.DecodeFour(req.Attr(radius.AcctSessionTime))
-	octIn := radius.DecodeFour(req.Attr(radius.AcctInputOctets))
-	octOut := radius.DecodeFour(req.Attr(radius.AcctOutputOctets))
+	count, e := model.NASSessionsClear(h.Storage, nasIp, radius.TerminateNASReboot)
+	if e != nil {
+		h.Logger.Printf("acct.onoff e=" + e.Error())
+		return
+	}
+	if h.Verbose {
+		h.Logger.Printf("acct.onoff status=%d nasIP=%s cleared %d sessions", status, nasIp, count)
+	}
 
-	packIn := radius.DecodeFour(req.Attr(radius.AcctInputPackets))
-	packOut := radius.DecodeFour(req.Attr(radius.AcctOutputPackets))
+	w.Write(req.Response(radius.AccountingResponse, nil, h.Verbose, h.Logger))
+}
+
+func (h *Handler) AcctStop(w io.Writer, req *radius.Packet) {
+	if e := radius.ValidateAcctRequest(req); e != "" {
+		h.Logger.Printf("acct.stop e=" + e)
+		return
+	}
+	sessModel := createSess(req)
+	user := sessModel.User
+	sess := sessModel.SessionID
+	nasIp := sessModel.NasIP
 
 	if h.Verbose {
 		h.Logger.Printf(
 			"acct.stop sess=%s for user=%s sessTime=%d octetsIn=%d octetsOut=%d",
-			sess, user, sessTime, octIn, octOut,
+			sess, user, sessModel.SessionTime, sessModel.BytesIn, sessModel.BytesOut,
 		)
 	}
 
-	sessModel := createSess(req)
-	if e := model.SessionUpdate(h.Storage, sessModel); e != nil {
+	delta, e := model.SessionUpdate(h.Storage, sessModel)
+	if e != nil {
 		h.Logger.Printf("acct.update e=" + e.Error())
 		return
 	}
@@ -117,7 +134,7 @@ func (h *Handler) AcctStop(w io.Writer, req *radius.Packet) {
 		h.Logger.Printf("acct.update e=" + e.Error())
 		return
 	}
-	queue.Queue(user, octIn, octOut, packIn, packOut)
+	queue.Queue(user, delta.BytesIn, delta.BytesOut, uint64(delta.PacketsIn), uint64(delta.PacketsOut))
 
 	w.Write(radius.DefaultPacket(req, radius.AccountingResponse, "Finished accounting.", h.Verbose, h.Logger))
 }
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/queue"
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/storage"
+)
+
+func acctRequest(status uint32, sessTime uint32, in uint32, inGiga uint32, out uint32) *radius.Packet {
+	return radius.NewRequest(radius.AccountingRequest, "secret", []radius.AttrEncoder{
+		radius.NewAttr(radius.AcctStatusType, radius.EncodeFour(status), 0),
+		radius.NewAttr(radius.AcctSessionId, []byte("1234"), 0),
+		radius.NewAttr(radius.UserName, []byte("user"), 0),
+		radius.NewAttr(radius.NASIPAddress, []byte{127, 0, 0, 1}, 0),
+		radius.NewAttr(radius.NASIdentifier, []byte("1"), 0),
+		radius.NewAttr(radius.NASPort, radius.EncodeFour(0), 0),
+		radius.NewAttr(radius.NASPortType, radius.EncodeFour(19), 0),
+		radius.NewAttr(radius.FramedIPAddress, []byte{127, 0, 0, 1}, 0),
+		radius.NewAttr(radius.CallingStationId, []byte("00-00-00-AA-AA-AA"), 0),
+		radius.NewAttr(radius.AcctSessionTime, radius.EncodeFour(sessTime), 0),
+		radius.NewAttr(radius.AcctInputOctets, radius.EncodeFour(in), 0),
+		radius.NewAttr(radius.AcctInputGigawords, radius.EncodeFour(inGiga), 0),
+		radius.NewAttr(radius.AcctOutputOctets, radius.EncodeFour(out), 0),
+		radius.NewAttr(radius.AcctInputPackets, radius.EncodeFour(sessTime), 0),
+		radius.NewAttr(radius.AcctOutputPackets, radius.EncodeFour(sessTime), 0),
+	})
+}
+
+// Interims carry session totals, only the growth may be accounted
+func TestAcctDelta(t *testing.T) {
+	store := storage.NewMemory()
+	store.AddUser("user", model.User{})
+	h := &Handler{Storage: store, Logger: log.New(ioutil.Discard, "", 0)}
+	queue.Flush()
+
+	steps := []struct {
+		name   string
+		handle func(w *bytes.Buffer, req *radius.Packet)
+		req    *radius.Packet
+	}{
+		{"start", func(w *bytes.Buffer, r *radius.Packet) { h.AcctBegin(w, r) }, acctRequest(1, 0, 0, 0, 0)},
+		{"interim", func(w *bytes.Buffer, r *radius.Packet) { h.AcctUpdate(w, r) }, acctRequest(3, 60, 1000, 1, 500)},
+		{"duplicate", func(w *bytes.Buffer, r *radius.Packet) { h.AcctUpdate(w, r) }, acctRequest(3, 60, 1000, 1, 500)},
+		{"interim", func(w *bytes.Buffer, r *radius.Packet) { h.AcctUpdate(w, r) }, acctRequest(3, 120, 3000, 1, 800)},
+		{"out-of-order", func(w *bytes.Buffer, r *radius.Packet) { h.AcctUpdate(w, r) }, acctRequest(3, 60, 1000, 1, 500)},
+		{"stop", func(w *bytes.Buffer, r *radius.Packet) { h.AcctStop(w, r) }, acctRequest(2, 150, 4000, 1, 900)},
+	}
+	for _, step := range steps {
+		w := new(bytes.Buffer)
+		step.handle(w, step.req)
+		if w.Len() == 0 {
+			t.Fatalf("%s: no Accounting-Response", step.name)
+		}
+	}
+
+	stat := queue.Flush()["user"]
+	if stat.InOctet != 1<<32+4000 || stat.OutOctet != 900 {
+		t.Fatalf("Accounted in=%d out=%d, expected in=%d out=900", stat.InOctet, stat.OutOctet, uint64(1<<32+4000))
+	}
+	if stat.InPacket != 150 || stat.OutPacket != 150 {
+		t.Fatalf("Accounted packets in=%d out=%d, expected 150", stat.InPacket, stat.OutPacket)
+	}
+	log := store.SessionLog()
+	if n, _ := store.CountSessions("user"); n != 0 || len(log) != 1 || log[0].BytesIn != 1<<32+4000 {
+		t.Fatalf("Session not archived with totals, log=%+v", log)
+	}
+}
+
+func TestSessionDeltaReset(t *testing.T) {
+	prev := model.Session{BytesIn: 5000, BytesOut: 100, SessionTime: 60}
+	delta, ok := model.SessionDelta(prev, model.Session{BytesIn: 300, BytesOut: 200, SessionTime: 120})
+	if !ok || delta.BytesIn != 300 || delta.BytesOut != 100 {
+		t.Fatalf("Counter reset not counted from zero, delta=%+v", delta)
+	}
+}
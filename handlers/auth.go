@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"io"
 	"net"
+	"time"
 
 	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/passwd"
 	"github.com/mpdroog/radiusd/radius"
 	"github.com/mpdroog/radiusd/radius/mschap"
 	"github.com/mpdroog/radiusd/radius/vendor"
@@ -16,8 +18,14 @@ func (h *Handler) Auth(w io.Writer, req *radius.Packet) {
 		h.Logger.Printf("auth.begin e=%s", e)
 		return
 	}
-	reply := []radius.AttrEncoder{}
-
+	if req.HasAttr(radius.EAPMessage) {
+		h.authEAP(w, req)
+		return
+	}
+	if req.HasAttr(radius.State) {
+		h.authChallenge(w, req)
+		return
+	}
 	user := string(req.Attr(radius.UserName))
 	limits, e := model.Auth(h.Storage, user)
 	if e != nil {
@@ -29,14 +37,45 @@ func (h *Handler) Auth(w io.Writer, req *radius.Packet) {
 		return
 	}
 
+	reply, msg := h.verify(req, user, limits)
+	if msg != "" {
+		w.Write(radius.DefaultPacket(req, radius.AccessReject, msg, h.Verbose, h.Logger))
+		return
+	}
+	if limits.TOTPSecret != nil && *limits.TOTPSecret != "" {
+		if !req.HasAttr(radius.UserPassword) {
+			// Only PAP can prompt for the code
+			w.Write(radius.DefaultPacket(req, radius.AccessReject, "Two-factor requires PAP", h.Verbose, h.Logger))
+			return
+		}
+		h.challenge(w, req, user)
+		return
+	}
+	h.authorize(w, req, user, limits, reply)
+}
+
+// Check PAP, CHAP or MS-CHAP credentials, returns the reply
+// attributes or the reason to reject
+func (h *Handler) verify(req *radius.Packet, user string, limits model.User) ([]radius.AttrEncoder, string) {
+	reply := []radius.AttrEncoder{}
+	stored, e := passwd.Parse(limits.Pass)
+	if e != nil {
+		h.Logger.Printf("auth.verify user=%s e=%s", user, e.Error())
+		return nil, "Server-side processing error"
+	}
+
 	if req.HasAttr(radius.UserPassword) {
 		pass := radius.DecryptPassword(req.Attr(radius.UserPassword), req)
-		if pass != limits.Pass {
-			w.Write(radius.DefaultPacket(req, radius.AccessReject, "Invalid password", h.Verbose, h.Logger))
-			return
+		ok, e := stored.Match(pass)
+		if e != nil {
+			h.Logger.Printf("PAP user=%s e=%s", user, e.Error())
+			return nil, "Server-side processing error"
+		}
+		if !ok {
+			return nil, "Invalid password"
 		}
 		if h.Verbose {
-			h.Logger.Printf("PAP login user=%s", user)
+			h.Logger.Printf("PAP login user=%s nas=%s", user, req.ClientName())
 		}
 	} else if req.HasAttr(radius.CHAPPassword) {
 		challenge := req.Attr(radius.CHAPChallenge)
@@ -44,12 +83,15 @@ func (h *Handler) Auth(w io.Writer, req *radius.Packet) {
 
 		// TODO: No challenge then use Request Authenticator
 
-		if !radius.CHAPMatch(limits.Pass, hash, challenge) {
-			w.Write(radius.DefaultPacket(req, radius.AccessReject, "Invalid password", h.Verbose, h.Logger))
-			return
+		pass, ok := stored.Cleartext()
+		if !ok {
+			return nil, "CHAP: Not supported for stored password"
+		}
+		if !radius.CHAPMatch(pass, hash, challenge) {
+			return nil, "Invalid password"
 		}
 		if h.Verbose {
-			h.Logger.Printf("CHAP login user=%s", user)
+			h.Logger.Printf("CHAP login user=%s nas=%s", user, req.ClientName())
 		}
 	} else {
 		// Search for MSCHAP attrs
@@ -63,10 +105,16 @@ func (h *Handler) Auth(w io.Writer, req *radius.Packet) {
 			}
 		}
 
+		if len(attrs) == 0 {
+			return nil, "No credentials"
+		}
 		if len(attrs) > 0 && len(attrs) != 2 {
-			w.Write(radius.DefaultPacket(req, radius.AccessReject, "MSCHAP: Missing attrs? MS-CHAP-Challenge/MS-CHAP-Response", h.Verbose, h.Logger))
-			return
+			return nil, "MSCHAP: Missing attrs? MS-CHAP-Challenge/MS-CHAP-Response"
 		} else if len(attrs) == 2 {
+			passHash, ok := stored.NTHash()
+			if !ok {
+				return nil, "MSCHAP: Not supported for stored password"
+			}
 			// Collect our data
 			challenge := mschap.DecodeChallenge(attrs[vendor.MSCHAPChallenge].Bytes()).Value
 			if _, isV1 := attrs[vendor.MSCHAPResponse]; isV1 {
@@ -75,26 +123,27 @@ func (h *Handler) Auth(w io.Writer, req *radius.Packet) {
 				if res.Flags == 0 {
 					// If it is zero, the NT-Response field MUST be ignored and
 					// the LM-Response field used.
-					w.Write(radius.DefaultPacket(req, radius.AccessReject, "MSCHAPv1: LM-Response not supported.", h.Verbose, h.Logger))
-					return
+					return nil, "MSCHAPv1: LM-Response not supported."
 				}
 				if bytes.Compare(res.LMResponse, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}) != 0 {
-					w.Write(radius.DefaultPacket(req, radius.AccessReject, "MSCHAPv1: LM-Response set.", h.Verbose, h.Logger))
-					return
+					return nil, "MSCHAPv1: LM-Response set."
 				}
 
 				// Check for correctness
-				calc, e := mschap.Encryptv1(challenge, limits.Pass)
+				calc, e := mschap.Encryptv1NT(challenge, passHash)
 				if e != nil {
 					h.Logger.Printf("MSCHAPv1: " + e.Error())
-					w.Write(radius.DefaultPacket(req, radius.AccessReject, "MSCHAPv1: Server-side processing error", h.Verbose, h.Logger))
-					return
+					return nil, "MSCHAPv1: Server-side processing error"
 				}
-				mppe, e := mschap.Mppev1(limits.Pass)
-				if e != nil {
-					h.Logger.Printf("MPPEv1: " + e.Error())
-					w.Write(radius.DefaultPacket(req, radius.AccessReject, "MPPEv1: Server-side processing error", h.Verbose, h.Logger))
-					return
+				mppe, types := mschap.Mppev1NT(passHash), mschap.MPPEv1TypesNT
+				if pass, ok := stored.Cleartext(); ok {
+					// LM-part needs the cleartext
+					mppe, e = mschap.Mppev1(pass)
+					if e != nil {
+						h.Logger.Printf("MPPEv1: " + e.Error())
+						return nil, "MPPEv1: Server-side processing error"
+					}
+					types = mschap.MPPEv1Types
 				}
 
 				if bytes.Compare(res.NTResponse, calc) != 0 {
@@ -104,11 +153,10 @@ func (h *Handler) Auth(w io.Writer, req *radius.Packet) {
 							user, calc, res.NTResponse,
 						)
 					}
-					w.Write(radius.DefaultPacket(req, radius.AccessReject, "Invalid password", h.Verbose, h.Logger))
-					return
+					return nil, "Invalid password"
 				}
 				if h.Verbose {
-					h.Logger.Printf("MSCHAPv1 login user=%s", user)
+					h.Logger.Printf("MSCHAPv1 login user=%s nas=%s", user, req.ClientName())
 				}
 
 				reply = append(reply, radius.VendorAttr{
@@ -123,7 +171,7 @@ func (h *Handler) Auth(w io.Writer, req *radius.Packet) {
 						/* encryption types, allow RC4[40/128bit] */
 						radius.VendorAttrString{
 							Type:  vendor.MSMPPEEncryptionTypes,
-							Value: []byte{0x0, 0x0, 0x0, 0x06},
+							Value: types,
 						},
 						/* mppe - encryption negotation key */
 						radius.VendorAttrString{
@@ -137,16 +185,14 @@ func (h *Handler) Auth(w io.Writer, req *radius.Packet) {
 				// MSCHAPv2
 				res := mschap.DecodeResponse2(attrs[vendor.MSCHAP2Response].Bytes())
 				if res.Flags != 0 {
-					w.Write(radius.DefaultPacket(req, radius.AccessReject, "MSCHAPv2: Flags should be set to 0", h.Verbose, h.Logger))
-					return
+					return nil, "MSCHAPv2: Flags should be set to 0"
 				}
-				enc, e := mschap.Encryptv2(challenge, res.PeerChallenge, user, limits.Pass)
+				enc, e := mschap.Encryptv2NT(challenge, res.PeerChallenge, user, passHash)
 				if e != nil {
 					h.Logger.Printf("MSCHAPv2: " + e.Error())
-					w.Write(radius.DefaultPacket(req, radius.AccessReject, "MSCHAPv2: Server-side processing error", h.Verbose, h.Logger))
-					return
+					return nil, "MSCHAPv2: Server-side processing error"
 				}
-				send, recv := mschap.Mmpev2(req.Secret(), limits.Pass, req.Auth, res.Response)
+				send, recv := mschap.Mmpev2NT(req.Secret(), passHash, req.Auth, res.Response)
 
 				if bytes.Compare(res.Response, enc.ChallengeResponse) != 0 {
 					if h.Verbose {
@@ -155,11 +201,10 @@ func (h *Handler) Auth(w io.Writer, req *radius.Packet) {
 							user, enc.ChallengeResponse, res.Response,
 						)
 					}
-					w.Write(radius.DefaultPacket(req, radius.AccessReject, "Invalid password", h.Verbose, h.Logger))
-					return
+					return nil, "Invalid password"
 				}
 				if h.Verbose {
-					h.Logger.Printf("MSCHAPv2 login user=%s", user)
+					h.Logger.Printf("MSCHAPv2 login user=%s nas=%s", user, req.ClientName())
 				}
 				// TODO: Framed-Protocol = PPP, Framed-Compression = Van-Jacobson-TCP-IP
 				reply = append(reply, radius.VendorAttr{
@@ -195,23 +240,59 @@ func (h *Handler) Auth(w io.Writer, req *radius.Packet) {
 				}.Encode())
 
 			} else {
-				w.Write(radius.DefaultPacket(req, radius.AccessReject, "MSCHAP: Response1/2 not found", h.Verbose, h.Logger))
-				return
+				return nil, "MSCHAP: Response1/2 not found"
 			}
 		}
 	}
 
+	return reply, ""
+}
+
+// Add limits to reply and accept if user has connections left
+func (h *Handler) authorize(w io.Writer, req *radius.Packet, user string, limits model.User, reply []radius.AttrEncoder) {
+	timeout, msg, e := policy(limits, time.Now())
+	if e != nil {
+		h.Logger.Printf("auth.policy user=%s e=%s", user, e.Error())
+		w.Write(h.reject(req, "Server-side processing error"))
+		return
+	}
+	if msg != "" {
+		w.Write(h.reject(req, msg))
+		return
+	}
+
 	conns, e := model.Conns(h.Storage, user)
 	if e != nil {
 		h.Logger.Printf("auth.begin e=" + e.Error())
 		return
 	}
 	if conns >= limits.SimultaneousUse {
-		w.Write(radius.DefaultPacket(req, radius.AccessReject, "Max conns reached", h.Verbose, h.Logger))
+		w.Write(h.reject(req, "Max conns reached"))
 		return
 	}
 
 	if limits.Ok {
+		// 0 is no limit, like NULL
+		if limits.SessionTimeout != nil && *limits.SessionTimeout > 0 && (timeout == 0 || *limits.SessionTimeout < timeout) {
+			timeout = *limits.SessionTimeout
+		}
+		if timeout > 0 {
+			reply = append(reply, radius.NewAttr(radius.SessionTimeout, radius.EncodeFour(timeout), 0))
+		}
+		if limits.IdleTimeout != nil && *limits.IdleTimeout > 0 {
+			reply = append(reply, radius.NewAttr(radius.IdleTimeout, radius.EncodeFour(*limits.IdleTimeout), 0))
+		}
+		if limits.InterimInterval != nil {
+			interim := *limits.InterimInterval
+			if h.MaxInterim > 0 && (interim == 0 || interim > h.MaxInterim) {
+				// 0 stops interims, reaper would close the session between updates
+				h.Logger.Printf("WARN: auth.interim user=%s interval=%d not within reaper=%d, using %d", user, interim, h.MaxInterim, h.Interim)
+				interim = h.Interim
+			}
+			if interim > 0 {
+				reply = append(reply, radius.NewAttr(radius.AcctInterimInterval, radius.EncodeFour(interim), 0))
+			}
+		}
 		if limits.DedicatedIP != nil {
 			reply = append(reply, radius.NewAttr(
 				radius.FramedIPAddress,
@@ -251,5 +332,5 @@ func (h *Handler) Auth(w io.Writer, req *radius.Packet) {
 		return
 	}
 
-	w.Write(radius.DefaultPacket(req, radius.AccessReject, "Invalid user/pass", h.Verbose, h.Logger))
+	w.Write(h.reject(req, "Invalid user/pass"))
 }
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/totp"
+)
+
+// Pending second factors, kept in storage so the follow-up
+// Access-Request can reach any node
+type Challenges struct {
+	timeout time.Duration
+	lock    sync.Mutex
+	sweep   time.Time
+}
+
+func NewChallenges(timeout time.Duration) *Challenges {
+	return &Challenges{
+		timeout: timeout,
+		sweep:   time.Now(),
+	}
+}
+
+// Remember user under a new random State
+func (c *Challenges) add(store model.Storage, user string) ([]byte, error) {
+	state := make([]byte, 16)
+	if _, e := rand.Read(state); e != nil {
+		return nil, e
+	}
+	now := time.Now()
+
+	c.lock.Lock()
+	sweep := now.Sub(c.sweep) > c.timeout
+	if sweep {
+		c.sweep = now
+	}
+	c.lock.Unlock()
+	if sweep {
+		if e := store.ExpireChallenges(now.Add(-c.timeout).Unix()); e != nil {
+			return nil, e
+		}
+	}
+
+	if e := store.AddChallenge(hex.EncodeToString(state), user, now.Unix()); e != nil {
+		return nil, e
+	}
+	return state, nil
+}
+
+// User for State, a State can only be used once
+func (c *Challenges) take(store model.Storage, state []byte) (string, bool, error) {
+	user, added, e := store.TakeChallenge(hex.EncodeToString(state))
+	if e == model.ErrNoRows {
+		return "", false, nil
+	}
+	if e != nil {
+		return "", false, e
+	}
+	if time.Since(time.Unix(added, 0)) > c.timeout {
+		return "", false, nil
+	}
+	return user, true, nil
+}
+
+// Ask for the one-time code after the password matched
+func (h *Handler) challenge(w io.Writer, req *radius.Packet, user string) {
+	if h.Challenges == nil {
+		w.Write(radius.DefaultPacket(req, radius.AccessReject, "Two-factor not enabled", h.Verbose, h.Logger))
+		return
+	}
+	state, e := h.Challenges.add(h.Storage, user)
+	if e != nil {
+		h.Logger.Printf("auth.challenge e=" + e.Error())
+		return
+	}
+	if h.Verbose {
+		h.Logger.Printf("TOTP challenge user=%s nas=%s", user, req.ClientName())
+	}
+	w.Write(req.Response(radius.AccessChallenge, []radius.AttrEncoder{
+		radius.NewAttr(radius.ReplyMessage, []byte("Enter one-time code"), 0),
+		radius.NewAttr(radius.State, state, 0),
+	}, h.Verbose, h.Logger))
+}
+
+// Follow-up Access-Request with State and the code in User-Password
+func (h *Handler) authChallenge(w io.Writer, req *radius.Packet) {
+	if h.Challenges == nil {
+		w.Write(radius.DefaultPacket(req, radius.AccessReject, "Unknown State", h.Verbose, h.Logger))
+		return
+	}
+	user, ok, e := h.Challenges.take(h.Storage, req.Attr(radius.State))
+	if e != nil {
+		h.Logger.Printf("auth.challenge e=" + e.Error())
+		return
+	}
+	if !ok {
+		w.Write(radius.DefaultPacket(req, radius.AccessReject, "Challenge expired", h.Verbose, h.Logger))
+		return
+	}
+	if user != string(req.Attr(radius.UserName)) {
+		w.Write(radius.DefaultPacket(req, radius.AccessReject, "Challenge for other user", h.Verbose, h.Logger))
+		return
+	}
+	if !req.HasAttr(radius.UserPassword) {
+		w.Write(radius.DefaultPacket(req, radius.AccessReject, "One-time code missing", h.Verbose, h.Logger))
+		return
+	}
+
+	limits, e := model.Auth(h.Storage, user)
+	if e != nil {
+		h.Logger.Printf("auth.challenge e=" + e.Error())
+		return
+	}
+	if limits.TOTPSecret == nil || *limits.TOTPSecret == "" {
+		w.Write(radius.DefaultPacket(req, radius.AccessReject, "Two-factor not enabled", h.Verbose, h.Logger))
+		return
+	}
+	code := radius.DecryptPassword(req.Attr(radius.UserPassword), req)
+	counter, ok, e := totp.Validate(*limits.TOTPSecret, code, time.Now())
+	if e != nil {
+		h.Logger.Printf("auth.challenge user=%s e=%s", user, e.Error())
+		w.Write(radius.DefaultPacket(req, radius.AccessReject, "Server-side processing error", h.Verbose, h.Logger))
+		return
+	}
+	if ok {
+		// Guard against replay of the code on any node
+		ok, e = h.Storage.UseTOTPCounter(user, counter)
+		if e != nil {
+			h.Logger.Printf("auth.challenge user=%s e=%s", user, e.Error())
+			w.Write(radius.DefaultPacket(req, radius.AccessReject, "Server-side processing error", h.Verbose, h.Logger))
+			return
+		}
+	}
+	if !ok {
+		w.Write(radius.DefaultPacket(req, radius.AccessReject, "Invalid one-time code", h.Verbose, h.Logger))
+		return
+	}
+	if h.Verbose {
+		h.Logger.Printf("TOTP login user=%s nas=%s", user, req.ClientName())
+	}
+	h.authorize(w, req, user, limits, []radius.AttrEncoder{})
+}
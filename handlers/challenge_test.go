@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/storage"
+)
+
+func TestChallenges(t *testing.T) {
+	store := storage.NewMemory()
+	c := NewChallenges(time.Minute)
+	state, e := c.add(store, "user")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Other node with the same storage
+	other := NewChallenges(time.Minute)
+	user, ok, e := other.take(store, state)
+	if e != nil || !ok || user != "user" {
+		t.Fatalf("State not shared user=%s ok=%t e=%v", user, ok, e)
+	}
+	if _, ok, e := c.take(store, state); e != nil || ok {
+		t.Fatalf("State used twice ok=%t e=%v", ok, e)
+	}
+}
+
+func TestChallengesExpire(t *testing.T) {
+	store := storage.NewMemory()
+	c := NewChallenges(time.Second)
+	old := time.Now().Add(-time.Minute).Unix()
+	if e := store.AddChallenge("00", "user", old); e != nil {
+		t.Fatal(e)
+	}
+	if _, ok, e := c.take(store, []byte{0}); e != nil || ok {
+		t.Fatalf("Expired State accepted ok=%t e=%v", ok, e)
+	}
+
+	if e := store.AddChallenge("01", "user", old); e != nil {
+		t.Fatal(e)
+	}
+	c.sweep = time.Unix(old, 0)
+	if _, e := c.add(store, "user"); e != nil {
+		t.Fatal(e)
+	}
+	if _, _, e := store.TakeChallenge("01"); e != model.ErrNoRows {
+		t.Fatalf("Expired State not swept e=%v", e)
+	}
+}
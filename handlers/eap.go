@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"crypto/x509"
+	"io"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/radius/eap"
+	"github.com/pkg/errors"
+)
+
+// Stored password for the EAP methods
+func (h *Handler) Password(user string) (string, error) {
+	limits, e := model.Auth(h.Storage, user)
+	if e != nil {
+		return "", e
+	}
+	return limits.Pass, nil
+}
+
+// EAP-TLS: first of SAN e-mail, SAN DNS or subject CN that is a user
+func (h *Handler) CertUser(cert *x509.Certificate) (string, error) {
+	names := append([]string{}, cert.EmailAddresses...)
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.Subject.CommonName)
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		limits, e := model.Auth(h.Storage, name)
+		if e != nil {
+			return "", e
+		}
+		if limits.Ok {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// EAP-TTLS inner request, same credential checks as Auth
+func (h *Handler) InnerAuth(req *radius.Packet) ([]radius.AttrEncoder, error) {
+	user := string(req.Attr(radius.UserName))
+	limits, e := model.Auth(h.Storage, user)
+	if e != nil {
+		return nil, e
+	}
+	if limits.Pass == "" {
+		return nil, errors.New("No such user")
+	}
+	reply, msg := h.verify(req, user, limits)
+	if msg != "" {
+		return nil, errors.New(msg)
+	}
+	return reply, nil
+}
+
+// EAP conversation, limits are checked after the method succeeded
+func (h *Handler) authEAP(w io.Writer, req *radius.Packet) {
+	if h.EAP == nil {
+		w.Write(h.reject(req, "EAP not enabled"))
+		return
+	}
+	res := h.EAP.Handle(req)
+	switch res.Code {
+	case 0:
+		// Silently discard
+		return
+	case radius.AccessAccept:
+	default:
+		w.Write(req.Response(res.Code, res.Attrs, h.Verbose, h.Logger))
+		return
+	}
+
+	user := res.Identity
+	limits, e := model.Auth(h.Storage, user)
+	if e != nil {
+		h.Logger.Printf("auth.begin e=" + e.Error())
+		return
+	}
+	if limits.TOTPSecret != nil && *limits.TOTPSecret != "" {
+		w.Write(h.reject(req, "Two-factor requires PAP"))
+		return
+	}
+	if h.Verbose {
+		h.Logger.Printf("EAP login user=%s nas=%s", user, req.ClientName())
+	}
+	h.authorize(w, req, user, limits, res.Attrs)
+}
+
+// Access-Reject with Reply-Message, with EAP-Failure for EAP requests
+func (h *Handler) reject(req *radius.Packet, msg string) []byte {
+	attrs := []radius.AttrEncoder{radius.NewAttr(radius.ReplyMessage, []byte(msg), 0)}
+	if req.HasAttr(radius.EAPMessage) {
+		attrs = append(attrs, eap.FailureAttrs(req)...)
+	}
+	return req.Response(radius.AccessReject, attrs, h.Verbose, h.Logger)
+}
@@ -4,10 +4,15 @@ import (
 	"log"
 
 	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/radius/eap"
 )
 
 type Handler struct {
 	model.Storage
 	*log.Logger
-	Verbose bool
+	Verbose    bool
+	EAP        *eap.Server // nil to reject EAP
+	Challenges *Challenges // TOTP second factor, nil to reject
+	Interim    uint32      // Reaper: Acct-Interim-Interval in sec sent instead of product intervals above MaxInterim
+	MaxInterim uint32      // Reaper: Interim*Multiple in sec, 0 if the reaper is off
 }
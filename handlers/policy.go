@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/pkg/errors"
+)
+
+// Account restrictions checked after the credentials, returns the
+// Session-Timeout in seconds (0 for none) or the reason to reject
+func policy(limits model.User, now time.Time) (uint32, string, error) {
+	if limits.BlockRemain != nil && *limits.BlockRemain <= 0 {
+		return 0, "Data block exhausted", nil
+	}
+	if limits.ActiveUntil == nil {
+		return 0, "", nil
+	}
+
+	until, e := activeUntil(*limits.ActiveUntil)
+	if e != nil {
+		return 0, "", e
+	}
+	left := until.Sub(now)
+	if left <= 0 {
+		return 0, "Account expired", nil
+	}
+	// Round up so the session never ends before the deadline
+	return uint32((left + time.Second - 1) / time.Second), "", nil
+}
+
+// Account becomes inactive at the start of the day (local time)
+func activeUntil(date string) (time.Time, error) {
+	if len(date) < 10 {
+		return time.Time{}, errors.Errorf("active_until invalid value=%s", date)
+	}
+	until, e := time.ParseInLocation("2006-01-02", date[:10], time.Local)
+	if e != nil {
+		return time.Time{}, errors.Wrapf(e, "active_until invalid value=%s", date)
+	}
+	return until, nil
+}
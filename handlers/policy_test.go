@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mpdroog/radiusd/model"
+)
+
+func TestPolicy(t *testing.T) {
+	now := time.Date(2020, 5, 1, 23, 0, 0, 0, time.Local)
+	zero, some := int64(0), int64(1024)
+	tomorrow, today := "2020-05-02", "2020-05-01"
+
+	tests := []struct {
+		limits  model.User
+		timeout uint32
+		msg     string
+	}{
+		{model.User{}, 0, ""},
+		{model.User{BlockRemain: &some}, 0, ""},
+		{model.User{BlockRemain: &zero}, 0, "Data block exhausted"},
+		{model.User{ActiveUntil: &today}, 0, "Account expired"},
+		{model.User{ActiveUntil: &tomorrow}, 3600, ""},
+	}
+	for i, test := range tests {
+		timeout, msg, e := policy(test.limits, now)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if timeout != test.timeout || msg != test.msg {
+			t.Errorf("%d: expected=%d/%q found=%d/%q", i, test.timeout, test.msg, timeout, msg)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	S "sync"
+	"testing"
+	"time"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/queue"
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/radius/mschap"
+	"github.com/mpdroog/radiusd/radius/vendor"
+	"github.com/mpdroog/radiusd/storage"
+	"github.com/mpdroog/radiusd/sync"
+)
+
+// User and password of test/vpnxs_radius.sql
+const (
+	testUser = "vpn293b50c891377b94c904b42396e45fd99d"
+	testPass = "derpderp"
+)
+
+// Handlers are registered once and call the Handler of the
+// running test
+var e2e struct {
+	once S.Once
+	lock S.Mutex
+	h    *Handler
+	addr string
+}
+
+func e2eHandler() *Handler {
+	e2e.lock.Lock()
+	defer e2e.lock.Unlock()
+	return e2e.h
+}
+
+// Serve the handlers of main.go on loopback with a fresh in-memory
+// storage holding the test user
+func testServer(t *testing.T) *storage.Memory {
+	logger := log.New(ioutil.Discard, "", 0)
+	e2e.once.Do(func() {
+		conn, e := radius.Listen("127.0.0.1:0")
+		if e != nil {
+			t.Fatal(e)
+		}
+		radius.HandleFunc(radius.AccessRequest, 0, func(w io.Writer, p *radius.Packet) { e2eHandler().Auth(w, p) })
+		radius.HandleFunc(radius.AccountingRequest, 1, func(w io.Writer, p *radius.Packet) { e2eHandler().AcctBegin(w, p) })
+		radius.HandleFunc(radius.AccountingRequest, 3, func(w io.Writer, p *radius.Packet) { e2eHandler().AcctUpdate(w, p) })
+		radius.HandleFunc(radius.AccountingRequest, 2, func(w io.Writer, p *radius.Packet) { e2eHandler().AcctStop(w, p) })
+		radius.HandleFunc(radius.AccountingRequest, 7, func(w io.Writer, p *radius.Packet) { e2eHandler().AcctOnOff(w, p) })
+		radius.HandleFunc(radius.AccountingRequest, 8, func(w io.Writer, p *radius.Packet) { e2eHandler().AcctOnOff(w, p) })
+
+		srv := &radius.Server{
+			Secret:    "secret",
+			CIDR:      []string{"127.0.0.1/32"},
+			DupWindow: -1,
+			Logger:    logger,
+		}
+		go srv.Serve(conn)
+		e2e.addr = conn.LocalAddr().String()
+	})
+
+	block := int64(1 << 20)
+	store := storage.NewMemory()
+	store.AddUser(testUser, model.User{Pass: testPass, SimultaneousUse: 1, BlockRemain: &block})
+
+	e2e.lock.Lock()
+	e2e.h = &Handler{Storage: store, Logger: logger, Challenges: NewChallenges(time.Minute)}
+	e2e.lock.Unlock()
+	queue.Flush()
+	return store
+}
+
+var fixtureAttrs = map[string]radius.AttributeType{
+	"user-name":            radius.UserName,
+	"user-password":        radius.UserPassword,
+	"chap-password":        radius.CHAPPassword,
+	"chap-challenge":       radius.CHAPChallenge,
+	"nas-ip-address":       radius.NASIPAddress,
+	"nas-identifier":       radius.NASIdentifier,
+	"nas-port":             radius.NASPort,
+	"nas-port-type":        radius.NASPortType,
+	"called-station-id":    radius.CalledStationId,
+	"calling-station-id":   radius.CallingStationId,
+	"connect-info":         radius.ConnectInfo,
+	"framed-ip-address":    radius.FramedIPAddress,
+	"acct-session-id":      radius.AcctSessionId,
+	"acct-status-type":     radius.AcctStatusType,
+	"acct-authentic":       radius.AcctAuthentic,
+	"acct-session-time":    radius.AcctSessionTime,
+	"acct-input-packets":   radius.AcctInputPackets,
+	"acct-output-packets":  radius.AcctOutputPackets,
+	"acct-input-octets":    radius.AcctInputOctets,
+	"acct-output-octets":   radius.AcctOutputOctets,
+	"acct-terminate-cause": radius.AcctTerminateCause,
+}
+
+// Dictionary values used in the fixtures
+var fixtureValues = map[string]uint32{
+	"Start":           1,
+	"Stop":            2,
+	"Interim-Update":  3,
+	"Accounting-On":   7,
+	"RADIUS":          1,
+	"User-Request":    1,
+	"Wireless-802.11": 19,
+}
+
+// Read a radclient file from test/, values in override replace the
+// file's. Like radclient CHAP-Password and MS-CHAP-Password hold the
+// cleartext and are hashed here.
+func fixture(t *testing.T, name string, override map[string]string) *radius.Packet {
+	f, e := os.Open("../test/" + name)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer f.Close()
+
+	type pair struct{ key, value string }
+	var pairs []pair
+	code := radius.AccessRequest
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		if v, ok := override[key]; ok {
+			value = v
+		}
+		switch key {
+		case "Packet-Type":
+			code = radius.PacketCode(mustAtoi(t, value))
+		case "Packet-Dst-Port":
+		default:
+			pairs = append(pairs, pair{key, value})
+		}
+	}
+	if e := scanner.Err(); e != nil {
+		t.Fatal(e)
+	}
+
+	p := radius.NewRequest(code, "secret", nil)
+	var chapChallenge []byte
+	for _, kv := range pairs {
+		if strings.ToLower(kv.key) == "chap-challenge" {
+			chapChallenge = []byte(kv.value)
+		}
+	}
+	for _, kv := range pairs {
+		var attr radius.AttrEncoder
+		switch strings.ToLower(kv.key) {
+		case "user-password":
+			attr = radius.NewAttr(radius.UserPassword, radius.EncryptPassword(kv.value, p), 0)
+		case "chap-password":
+			h := md5.New()
+			h.Write([]byte{p.Identifier})
+			h.Write([]byte(kv.value))
+			h.Write(chapChallenge)
+			attr = radius.NewAttr(radius.CHAPPassword, append([]byte{p.Identifier}, h.Sum(nil)...), 0)
+		case "ms-chap-password":
+			challenge := make([]byte, 8)
+			if _, e := rand.Read(challenge); e != nil {
+				t.Fatal(e)
+			}
+			nt, e := mschap.Encryptv1(challenge, kv.value)
+			if e != nil {
+				t.Fatal(e)
+			}
+			// Ident, Flags (use NT-Response), LM-Response, NT-Response
+			res := append([]byte{1, 1}, make([]byte, 24)...)
+			p.Attrs = append(p.Attrs, msAttr(vendor.MSCHAPChallenge, challenge))
+			attr = msAttr(vendor.MSCHAPResponse, append(res, nt...))
+		case "ms-chap-challenge":
+			attr = msAttr(vendor.MSCHAPChallenge, mustHex(t, kv.value))
+		case "ms-chap2-response":
+			attr = msAttr(vendor.MSCHAP2Response, mustHex(t, kv.value))
+		default:
+			typ, ok := fixtureAttrs[strings.ToLower(kv.key)]
+			if !ok {
+				t.Fatalf("%s: unknown attribute %s", name, kv.key)
+			}
+			attr = radius.NewAttr(typ, fixtureValue(t, typ, kv.value), 0)
+		}
+		p.Attrs = append(p.Attrs, attr)
+	}
+	return p
+}
+
+func fixtureValue(t *testing.T, typ radius.AttributeType, value string) []byte {
+	switch typ {
+	case radius.NASIPAddress, radius.FramedIPAddress:
+		return net.ParseIP(value).To4()
+	case radius.NASPort, radius.NASPortType, radius.AcctStatusType, radius.AcctAuthentic,
+		radius.AcctSessionTime, radius.AcctInputPackets, radius.AcctOutputPackets,
+		radius.AcctInputOctets, radius.AcctOutputOctets, radius.AcctTerminateCause:
+		if n, ok := fixtureValues[value]; ok {
+			return radius.EncodeFour(n)
+		}
+		return radius.EncodeFour(uint32(mustAtoi(t, value)))
+	}
+	return []byte(value)
+}
+
+func msAttr(typ vendor.AttributeType, value []byte) radius.AttrEncoder {
+	return radius.VendorAttr{
+		Type:     radius.VendorSpecific,
+		VendorId: vendor.Microsoft,
+		Values:   []radius.VendorAttrString{{Type: typ, Value: value}},
+	}.Encode()
+}
+
+func mustAtoi(t *testing.T, s string) int {
+	n, e := strconv.Atoi(s)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return n
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	b, e := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	return b
+}
+
+// Send fixture to the server, fails without (valid) response
+func exchange(t *testing.T, name string, override map[string]string) *radius.Packet {
+	res, e := radius.Exchange(e2e.addr, fixture(t, name, override), 2*time.Second, false, nil)
+	if e != nil {
+		t.Fatalf("%s: %s", name, e)
+	}
+	return res
+}
+
+func expectCode(t *testing.T, name string, res *radius.Packet, code radius.PacketCode) {
+	if res.Code != code {
+		t.Fatalf("%s: expected %s, found=%s msg=%s", name, code, res.Code, res.Attr(radius.ReplyMessage))
+	}
+}
+
+func TestServerAuth(t *testing.T) {
+	testServer(t)
+
+	for _, name := range []string{"auth.txt", "auth-chap.txt", "auth-mschapv1.txt", "auth-mschapv2.txt"} {
+		expectCode(t, name, exchange(t, name, nil), radius.AccessAccept)
+	}
+
+	wrong := map[string]string{"User-Password": "wrong", "CHAP-Password": "wrong", "MS-CHAP-Password": "wrong"}
+	for _, name := range []string{"auth.txt", "auth-chap.txt", "auth-mschapv1.txt"} {
+		expectCode(t, name, exchange(t, name, wrong), radius.AccessReject)
+	}
+	res := exchange(t, "auth.txt", map[string]string{"User-Name": "unknown"})
+	expectCode(t, "unknown user", res, radius.AccessReject)
+}
+
+func TestServerSimultaneousUse(t *testing.T) {
+	testServer(t)
+
+	expectCode(t, "acct-start.txt", exchange(t, "acct-start.txt", nil), radius.AccountingResponse)
+	res := exchange(t, "auth.txt", nil)
+	expectCode(t, "auth.txt", res, radius.AccessReject)
+	if string(res.Attr(radius.ReplyMessage)) != "Max conns reached" {
+		t.Fatalf("Unexpected reject msg=%s", res.Attr(radius.ReplyMessage))
+	}
+
+	expectCode(t, "acct-stop.txt", exchange(t, "acct-stop.txt", nil), radius.AccountingResponse)
+	expectCode(t, "auth.txt", exchange(t, "auth.txt", nil), radius.AccessAccept)
+}
+
+// Replace the running Handler, it may still be read by the server
+func setReaper(interim uint32, max uint32) {
+	e2e.lock.Lock()
+	defer e2e.lock.Unlock()
+	h := *e2e.h
+	h.Interim, h.MaxInterim = interim, max
+	e2e.h = &h
+}
+
+func TestServerLimits(t *testing.T) {
+	store := testServer(t)
+	setReaper(300, 900)
+
+	zero, interim := uint32(0), uint32(3600)
+	store.AddUser(testUser, model.User{Pass: testPass, SimultaneousUse: 1, SessionTimeout: &zero, IdleTimeout: &zero, InterimInterval: &interim})
+	res := exchange(t, "auth.txt", nil)
+	expectCode(t, "auth.txt", res, radius.AccessAccept)
+	if res.HasAttr(radius.SessionTimeout) || res.HasAttr(radius.IdleTimeout) {
+		t.Fatal("Session-Timeout or Idle-Timeout 0 sent")
+	}
+	if n := radius.DecodeFour(res.Attr(radius.AcctInterimInterval)); n != 300 {
+		t.Fatalf("Acct-Interim-Interval not capped to reaper, found=%d", n)
+	}
+
+	// 0 would stop Interim-Updates
+	store.AddUser(testUser, model.User{Pass: testPass, SimultaneousUse: 1, InterimInterval: &zero})
+	res = exchange(t, "auth.txt", nil)
+	expectCode(t, "auth.txt", res, radius.AccessAccept)
+	if n := radius.DecodeFour(res.Attr(radius.AcctInterimInterval)); n != 300 {
+		t.Fatalf("Acct-Interim-Interval 0 not replaced by reaper, found=%d", n)
+	}
+
+	// Without reaper nothing is sent
+	setReaper(0, 0)
+	res = exchange(t, "auth.txt", nil)
+	expectCode(t, "auth.txt", res, radius.AccessAccept)
+	if res.HasAttr(radius.AcctInterimInterval) {
+		t.Fatal("Acct-Interim-Interval 0 sent")
+	}
+}
+
+func TestServerAccounting(t *testing.T) {
+	store := testServer(t)
+
+	for _, name := range []string{"acct-start.txt", "acct-update.txt", "acct-stop.txt"} {
+		expectCode(t, name, exchange(t, name, nil), radius.AccountingResponse)
+	}
+	if n, _ := store.CountSessions(testUser); n != 0 {
+		t.Fatalf("Expected session to be closed, found=%d", n)
+	}
+	log := store.SessionLog()
+	if len(log) != 1 || log[0].BytesIn != 3407 || log[0].BytesOut != 867 || log[0].SessionTime != 30 {
+		t.Fatalf("Session not archived with totals, log=%+v", log)
+	}
+
+	sync.Force(store, "test", nil, false, e2eHandler().Logger)
+	acct := store.Accounting()
+	if len(acct) != 1 || acct[0].BytesIn != 3407 || acct[0].BytesOut != 867 || acct[0].PacketsIn != 25 {
+		t.Fatalf("Accounting wrong, acct=%+v", acct)
+	}
+	if remain, _ := store.SelectRemain(testUser); remain != 1<<20-3407-867 {
+		t.Fatalf("Block not reduced, remain=%d", remain)
+	}
+
+	// NAS reboot closes sessions started before it
+	expectCode(t, "acct-start.txt", exchange(t, "acct-start.txt", nil), radius.AccountingResponse)
+	time.Sleep(time.Until(time.Unix(time.Now().Unix()+1, 0)))
+	expectCode(t, "acct-on.txt", exchange(t, "acct-on.txt", nil), radius.AccountingResponse)
+	if n, _ := store.CountSessions(testUser); n != 0 {
+		t.Fatalf("Expected Accounting-On to clear the session, found=%d", n)
+	}
+	log = store.SessionLog()
+	if len(log) != 2 || log[1].TerminateCause != radius.TerminateNASReboot {
+		t.Fatalf("Session not archived on Accounting-On, log=%+v", log)
+	}
+}
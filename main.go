@@ -3,10 +3,13 @@ package main
 import (
 	"flag"
 	S "sync"
+	"time"
 
 	"github.com/mpdroog/radiusd/config"
 	"github.com/mpdroog/radiusd/handlers"
 	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/radius/eap"
+	"github.com/mpdroog/radiusd/reaper"
 	"github.com/mpdroog/radiusd/storage"
 	"github.com/mpdroog/radiusd/sync"
 )
@@ -17,19 +20,59 @@ func listenAndServe(l config.Listener) {
 	defer wg.Done()
 
 	if config.Verbose {
-		config.Log.Printf("Listening on " + l.Addr)
+		config.Log.Printf("Listening on " + l.Addr + " (" + l.Transport + ")")
 	}
-	conn, e := radius.Listen(l.Addr)
-	if e != nil {
-		panic(e)
+	srv := &radius.Server{
+		Clients:   clients,
+		Secret:    l.Secret,
+		CIDR:      l.CIDR,
+		Acct:      l.Type == "acct",
+		Workers:   l.Workers,
+		QueueSize: l.QueueSize,
+		Timeout:   l.Timeout,
+		DupWindow: l.DupWindow,
+
+		IdleTimeout: l.IdleTimeout,
+		MaxInFlight: l.MaxInFlight,
+
+		Verbose: config.Verbose,
+		Logger:  config.Log,
 	}
-	config.Sock = append(config.Sock, conn)
-	if e := radius.Serve(conn, l.Secret, l.CIDR, config.Verbose, config.Log); e != nil {
+
+	var serveErr error
+	switch l.Transport {
+	case "tls":
+		tlsConf, e := radius.TLSConfig(l.Cert, l.Key, l.CA)
+		if e != nil {
+			panic(e)
+		}
+		ln, e := radius.ListenTLS(l.Addr, tlsConf)
+		if e != nil {
+			panic(e)
+		}
+		config.Sock = append(config.Sock, ln)
+		serveErr = srv.ServeTLS(ln)
+	case "tcp":
+		ln, e := radius.ListenTCP(l.Addr)
+		if e != nil {
+			panic(e)
+		}
+		config.Sock = append(config.Sock, ln)
+		serveErr = srv.ServeTCP(ln)
+	default:
+		conn, e := radius.Listen(l.Addr)
+		if e != nil {
+			panic(e)
+		}
+		config.Sock = append(config.Sock, conn)
+		serveErr = srv.Serve(conn)
+	}
+	if serveErr != nil {
 		if config.Stopping {
 			// Ignore close errors
 			return
 		}
-		panic(e)
+		panic(serveErr)
 	}
 }
 
@@ -61,26 +104,67 @@ func main() {
 	   15      Reserved for Failed
 	*/
 
-	storage, e := storage.NewMySQL(config.C.Dsn)
+	storage, e := storage.Open(config.C.Driver, config.C.Dsn)
 	if e != nil {
 		panic(e)
 	}
-	if e := storage.Strict(); e != nil {
+
+	if e := loadClients(storage); e != nil {
 		panic(e)
 	}
 
 	h := &handlers.Handler{
-		Storage: storage,
-		Logger:  config.Log,
-		Verbose: config.Verbose,
+		Storage:    storage,
+		Logger:     config.Log,
+		Verbose:    config.Verbose,
+		Challenges: handlers.NewChallenges(config.C.TOTP.Timeout),
+	}
+	if r := config.C.Reaper; r.Interim > 0 {
+		h.Interim = uint32(r.Interim / time.Second)
+		h.MaxInterim = h.Interim * uint32(r.Multiple)
+	}
+	var methods []eap.Type
+	for _, name := range config.C.EAP.Methods {
+		t, ok := eap.TypeByName(name)
+		if !ok {
+			panic("eap.Methods: unsupported " + name)
+		}
+		methods = append(methods, t)
+	}
+	h.EAP = eap.NewServer(methods, config.C.EAP.Name, config.C.EAP.Timeout, h.Password, config.Verbose, config.Log)
+	if config.C.EAP.Cert != "" {
+		h.EAP.TLS, e = eap.TLSConfig(config.C.EAP.Cert, config.C.EAP.Key)
+		if e != nil {
+			panic(e)
+		}
+		h.EAP.Inner = h.InnerAuth
+	}
+	if config.C.EAP.CA != "" {
+		h.EAP.ClientTLS, e = eap.ClientTLSConfig(h.EAP.TLS, config.C.EAP.CA, config.C.EAP.CRL)
+		if e != nil {
+			panic(e)
+		}
+		h.EAP.CertUser = h.CertUser
 	}
 	radius.HandleFunc(radius.AccessRequest, 0, h.Auth)
 	radius.HandleFunc(radius.AccountingRequest, 1, h.AcctBegin)
 	radius.HandleFunc(radius.AccountingRequest, 3, h.AcctUpdate)
 	radius.HandleFunc(radius.AccountingRequest, 2, h.AcctStop)
+	radius.HandleFunc(radius.AccountingRequest, 7, h.AcctOnOff)
+	radius.HandleFunc(radius.AccountingRequest, 8, h.AcctOnOff)
 
-	go Control()
-	go sync.Loop(storage, config.Hostname, config.Verbose, config.Log)
+	quota := &sync.Quota{
+		Action:    config.C.Quota.Action,
+		RateLimit: config.C.Quota.RateLimit,
+		Port:      config.C.DynAuth.Port,
+		Timeout:   config.C.DynAuth.Timeout,
+		Secret:    dynAuthSecret,
+	}
+	go Control(storage)
+	go sync.Loop(storage, config.Hostname, quota, config.Verbose, config.Log)
+	if r := config.C.Reaper; r.Interim > 0 {
+		go reaper.Loop(storage, r.Interim*time.Duration(r.Multiple), r.Every, config.Verbose, config.Log)
+	}
 
 	wg = new(S.WaitGroup)
 	for _, listen := range config.C.Listen {
@@ -90,5 +174,5 @@ func main() {
 	wg.Wait()
 
 	// Write all stats
-	sync.Force(storage, config.Hostname, config.Verbose, config.Log)
+	sync.Force(storage, config.Hostname, quota, config.Verbose, config.Log)
 }
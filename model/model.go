@@ -1,5 +1,11 @@
 package model
 
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
 type User struct {
 	Pass            string
 	ActiveUntil     *string // Account active until YYYY-MM-DD
@@ -9,17 +15,31 @@ type User struct {
 	Ratelimit       *string
 	DnsOne          *string
 	DnsTwo          *string
+	TOTPSecret      *string // Second factor required if set
+	InterimInterval *uint32 // Product: Acct-Interim-Interval in sec
+	SessionTimeout  *uint32 // Product: Max session length in sec
+	IdleTimeout     *uint32 // Product: Idle-Timeout in sec
 	Ok              bool
 }
 type Session struct {
-	BytesIn     uint32
-	BytesOut    uint32
+	BytesIn     uint64 // Octets+Gigawords
+	BytesOut    uint64
 	PacketsIn   uint32
 	PacketsOut  uint32
 	SessionID   string
 	SessionTime uint32
 	User        string
 	NasIP       string
+	AssignedIP  string
+	ClientIP    string
+}
+type NAS struct {
+	Name   string
+	CIDR   string
+	Secret string
+	Type   string
+
+	RequireMessageAuthenticator bool
 }
 type UserLimits struct {
 	Exists bool
@@ -38,6 +58,10 @@ func Limits(storage Storage, user string) (UserLimits, error) {
 	return storage.GetLimits(user)
 }
 
+func NASList(storage Storage) ([]NAS, error) {
+	return storage.GetNASList()
+}
+
 func SessionAdd(storage Storage, sessionId, user, nasIp, assignedIp, clientIp string) error {
 	exists, e := storage.IsSessionExists(user, sessionId, nasIp)
 	if e != nil {
@@ -51,17 +75,73 @@ func SessionAdd(storage Storage, sessionId, user, nasIp, assignedIp, clientIp st
 	return storage.CreateSession(user, sessionId, nasIp, assignedIp, clientIp)
 }
 
-func SessionUpdate(storage Storage, s Session) error {
-	return storage.UpdateSession(
-		s.User,
-		s.SessionID,
-		s.NasIP,
-		int(s.BytesIn),
-		int(s.BytesOut),
-		int(s.PacketsIn),
-		int(s.PacketsOut),
-		int(s.SessionTime),
-	)
+// Counters s added since prev, false for a duplicate or an update
+// older than prev (out-of-order retransmit). A counter lower than
+// before is a reset on the NAS and counts from zero.
+func SessionDelta(prev Session, s Session) (Session, bool) {
+	if s.SessionTime < prev.SessionTime {
+		return Session{}, false
+	}
+	if s.SessionTime == prev.SessionTime && s.BytesIn == prev.BytesIn && s.BytesOut == prev.BytesOut &&
+		s.PacketsIn == prev.PacketsIn && s.PacketsOut == prev.PacketsOut {
+		return Session{}, false
+	}
+
+	delta := s
+	if s.BytesIn >= prev.BytesIn {
+		delta.BytesIn -= prev.BytesIn
+	}
+	if s.BytesOut >= prev.BytesOut {
+		delta.BytesOut -= prev.BytesOut
+	}
+	if s.PacketsIn >= prev.PacketsIn {
+		delta.PacketsIn -= prev.PacketsIn
+	}
+	if s.PacketsOut >= prev.PacketsOut {
+		delta.PacketsOut -= prev.PacketsOut
+	}
+	delta.SessionTime -= prev.SessionTime
+	return delta, true
+}
+
+// Store the cumulative counters of s, returns the delta to account
+// (zero for duplicates and out-of-order updates)
+func SessionUpdate(storage Storage, s Session) (Session, error) {
+	for try := 0; try < 3; try++ {
+		prev, e := storage.GetSession(s.User, s.SessionID, s.NasIP)
+		if e != nil {
+			return Session{}, e
+		}
+		delta, ok := SessionDelta(prev, s)
+		if !ok {
+			return Session{}, nil
+		}
+
+		e = storage.UpdateSession(
+			s.User,
+			s.SessionID,
+			s.NasIP,
+			int64(s.BytesIn),
+			int64(s.BytesOut),
+			int(s.PacketsIn),
+			int(s.PacketsOut),
+			int(s.SessionTime),
+			int(prev.SessionTime),
+		)
+		if errors.Cause(e) == ErrUpdateSession {
+			// Updated by another request meanwhile, compare again
+			continue
+		}
+		if e != nil {
+			return Session{}, e
+		}
+		return delta, nil
+	}
+	return Session{}, errors.Wrapf(ErrUpdateSession, "sess=%s user=%s", s.SessionID, s.User)
+}
+
+func SessionGet(storage Storage, sessionId, user, nasIp string) (Session, error) {
+	return storage.GetSession(user, sessionId, nasIp)
 }
 
 func SessionRemove(storage Storage, sessionId, user, nasIp string) error {
@@ -72,3 +152,9 @@ func SessionRemove(storage Storage, sessionId, user, nasIp string) error {
 func SessionLog(storage Storage, sessionId string, user string, nasIp string) error {
 	return storage.ArchiveSession(user, sessionId, nasIp)
 }
+
+// Log and remove all sessions of a NAS started before now (i.e. it
+// rebooted), returns the amount of sessions removed
+func NASSessionsClear(storage Storage, nasIp string, cause uint32) (int64, error) {
+	return storage.ClearNASSessions(nasIp, cause, time.Now().Unix())
+}
@@ -14,9 +14,18 @@ type Storage interface {
 	GetUser(name string) (user User, err error)
 	CountSessions(name string) (count int, err error)
 	GetLimits(name string) (user UserLimits, err error)
+	GetNASList() (list []NAS, err error)
 	IsSessionExists(name string, sessID string, nasIP string) (exists bool, err error)
+	GetSession(name string, sessID string, nasIP string) (sess Session, err error)
 	CreateSession(name string, sessID string, nasIP string, assignedIP string, clientIP string) error
-	UpdateSession(name string, sessID string, nasIP string, rx int, tx int, rxPackets int, txPackets int, duration int) error
+	UpdateSession(name string, sessID string, nasIP string, rx int64, tx int64, rxPackets int, txPackets int, duration int, prevDuration int) error
 	FinishSession(name string, sessID string, nasIP string) error
 	ArchiveSession(name string, sessID string, nasIP string) error
+	ClearNASSessions(nasIP string, cause uint32, before int64) (count int64, err error)
+
+	// TOTP challenges, shared by all nodes
+	AddChallenge(state string, name string, added int64) error
+	TakeChallenge(state string) (name string, added int64, err error)
+	ExpireChallenges(before int64) error
+	UseTOTPCounter(name string, counter uint64) (ok bool, err error)
 }
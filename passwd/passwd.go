@@ -0,0 +1,159 @@
+// Stored credentials in user.pass, the format is picked by prefix:
+//
+//	{cleartext}secret   (or no prefix) PAP, CHAP, MS-CHAP
+//	{nthash}<32 hex>    PAP, MS-CHAP
+//	{bcrypt}$2a$...     (or $2a$/$2b$/$2y$) PAP
+//	{argon2}$argon2id$  (or $argon2id$) PAP
+package passwd
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/mpdroog/radiusd/radius/mschap"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Format int
+
+const (
+	Cleartext Format = iota
+	NTHash
+	Bcrypt
+	Argon2
+)
+
+// Auth method the stored format must satisfy
+type Method int
+
+const (
+	PAP    Method = iota
+	CHAP          // CHAP and EAP-MD5, needs the cleartext
+	MSCHAP        // MS-CHAPv1/v2 and EAP-MSCHAPv2, needs the NT-Hash
+)
+
+var ErrFormat = errors.New("passwd: invalid stored password")
+
+type Password struct {
+	Format Format
+	Value  string // Without prefix
+}
+
+// Parse user.pass
+func Parse(stored string) (Password, error) {
+	prefixes := []struct {
+		prefix string
+		format Format
+	}{
+		{"{cleartext}", Cleartext},
+		{"{nthash}", NTHash},
+		{"{bcrypt}", Bcrypt},
+		{"{argon2}", Argon2},
+		{"$2a$", Bcrypt},
+		{"$2b$", Bcrypt},
+		{"$2y$", Bcrypt},
+		{"$argon2id$", Argon2},
+	}
+	p := Password{Format: Cleartext, Value: stored}
+	for _, pfx := range prefixes {
+		if strings.HasPrefix(stored, pfx.prefix) {
+			p.Format = pfx.format
+			if pfx.prefix[0] == '{' {
+				p.Value = stored[len(pfx.prefix):]
+			}
+			break
+		}
+	}
+
+	if p.Format == NTHash {
+		if b, e := hex.DecodeString(p.Value); e != nil || len(b) != 16 {
+			return p, ErrFormat
+		}
+	}
+	return p, nil
+}
+
+// Stored format can verify method
+func (p Password) Supports(m Method) bool {
+	switch m {
+	case PAP:
+		return true
+	case CHAP:
+		return p.Format == Cleartext
+	case MSCHAP:
+		return p.Format == Cleartext || p.Format == NTHash
+	}
+	return false
+}
+
+// Cleartext for CHAP, false if not stored as such
+func (p Password) Cleartext() (string, bool) {
+	return p.Value, p.Format == Cleartext
+}
+
+// NT-Hash for MS-CHAP, false if it can't be derived
+func (p Password) NTHash() ([]byte, bool) {
+	switch p.Format {
+	case Cleartext:
+		return mschap.NTHash(p.Value), true
+	case NTHash:
+		b, e := hex.DecodeString(p.Value)
+		return b, e == nil
+	}
+	return nil, false
+}
+
+// Compare PAP password
+func (p Password) Match(pass string) (bool, error) {
+	switch p.Format {
+	case Cleartext:
+		return subtle.ConstantTimeCompare([]byte(pass), []byte(p.Value)) == 1, nil
+	case NTHash:
+		hash, _ := p.NTHash()
+		return subtle.ConstantTimeCompare(mschap.NTHash(pass), hash) == 1, nil
+	case Bcrypt:
+		e := bcrypt.CompareHashAndPassword([]byte(p.Value), []byte(pass))
+		if e == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return e == nil, e
+	case Argon2:
+		return argon2Match(p.Value, pass)
+	}
+	return false, ErrFormat
+}
+
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash> (PHC string, raw base64)
+func argon2Match(encoded string, pass string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, ErrFormat
+	}
+	var (
+		version      int
+		memory, time uint32
+		threads      uint8
+	)
+	if _, e := fmt.Sscanf(parts[2], "v=%d", &version); e != nil || version != argon2.Version {
+		return false, ErrFormat
+	}
+	if _, e := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); e != nil {
+		return false, ErrFormat
+	}
+	salt, e := base64.RawStdEncoding.DecodeString(parts[4])
+	if e != nil {
+		return false, ErrFormat
+	}
+	hash, e := base64.RawStdEncoding.DecodeString(parts[5])
+	if e != nil || len(hash) == 0 {
+		return false, ErrFormat
+	}
+
+	calc := argon2.IDKey([]byte(pass), salt, time, memory, threads, uint32(len(hash)))
+	return subtle.ConstantTimeCompare(calc, hash) == 1, nil
+}
@@ -0,0 +1,68 @@
+package passwd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestFormats(t *testing.T) {
+	b, e := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if e != nil {
+		t.Fatal(e)
+	}
+	salt := []byte("0123456789abcdef")
+	a := fmt.Sprintf(
+		"$argon2id$v=%d$m=1024,t=1,p=1$%s$%s", argon2.Version,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte("password"), salt, 1, 1024, 1, 32)),
+	)
+
+	tests := map[string][]Method{
+		"password":            {PAP, CHAP, MSCHAP},
+		"{cleartext}password": {PAP, CHAP, MSCHAP},
+		"{nthash}8846F7EAEE8FB117AD06BDD830B7586C": {PAP, MSCHAP},
+		"{bcrypt}" + string(b):                     {PAP},
+		string(b):                                  {PAP},
+		"{argon2}" + a:                             {PAP},
+		a:                                          {PAP},
+	}
+	for stored, methods := range tests {
+		p, e := Parse(stored)
+		if e != nil {
+			t.Fatalf("%s: %s", stored, e)
+		}
+		for _, m := range []Method{PAP, CHAP, MSCHAP} {
+			expect := false
+			for _, ok := range methods {
+				expect = expect || ok == m
+			}
+			if p.Supports(m) != expect {
+				t.Errorf("%s: Supports(%d) expected=%v", stored, m, expect)
+			}
+		}
+
+		if ok, e := p.Match("password"); !ok || e != nil {
+			t.Errorf("%s: password rejected e=%v", stored, e)
+		}
+		if ok, _ := p.Match("wrong"); ok {
+			t.Errorf("%s: wrong password accepted", stored)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	if _, e := Parse("{nthash}8846"); e != ErrFormat {
+		t.Fatalf("Short NT-Hash accepted")
+	}
+	p, e := Parse("$argon2id$v=19$garbage")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e := p.Match("password"); e != ErrFormat {
+		t.Fatalf("Invalid argon2 accepted")
+	}
+}
@@ -4,14 +4,15 @@
 package queue
 
 import (
+	"math"
 	"sync"
 )
 
 type Stat struct {
-	InOctet   uint32
-	OutOctet  uint32
-	InPacket  uint32
-	OutPacket uint32
+	InOctet   uint64
+	OutOctet  uint64
+	InPacket  uint64
+	OutPacket uint64
 }
 
 var remains map[string]Stat
@@ -22,20 +23,29 @@ func init() {
 	lock = new(sync.Mutex)
 }
 
+// a+b, stuck at the max instead of wrapping around
+func add(a uint64, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+// Octets in+out
+func (s Stat) Octets() uint64 {
+	return add(s.InOctet, s.OutOctet)
+}
+
 // Add to queue
-func Queue(user string, in uint32, out uint32, inPack uint32, outPack uint32) {
+func Queue(user string, in uint64, out uint64, inPack uint64, outPack uint64) {
 	lock.Lock()
 	defer lock.Unlock()
 
-	remain, ok := remains[user]
-	if ok {
-		remain.InOctet += in
-		remain.OutOctet += out
-		remain.InPacket += inPack
-		remain.OutPacket += outPack
-	} else {
-		remain = Stat{InOctet: in, OutOctet: out, InPacket: inPack, OutPacket: outPack}
-	}
+	remain := remains[user]
+	remain.InOctet = add(remain.InOctet, in)
+	remain.OutOctet = add(remain.OutOctet, out)
+	remain.InPacket = add(remain.InPacket, inPack)
+	remain.OutPacket = add(remain.OutPacket, outPack)
 	remains[user] = remain
 }
 
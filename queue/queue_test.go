@@ -0,0 +1,23 @@
+package queue
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQueueOverflow(t *testing.T) {
+	Flush()
+	Queue("user", 1<<32, math.MaxUint64-10, 1, 2)
+	Queue("user", 1<<32, 20, 1, 2)
+
+	stat := Flush()["user"]
+	if stat.InOctet != 1<<33 {
+		t.Fatalf("InOctet expected=%d found=%d", uint64(1<<33), stat.InOctet)
+	}
+	if stat.OutOctet != math.MaxUint64 || stat.Octets() != math.MaxUint64 {
+		t.Fatalf("OutOctet wrapped, found=%d", stat.OutOctet)
+	}
+	if stat.InPacket != 2 || stat.OutPacket != 4 {
+		t.Fatalf("Packets expected=2/4 found=%d/%d", stat.InPacket, stat.OutPacket)
+	}
+}
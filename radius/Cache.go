@@ -0,0 +1,85 @@
+// Duplicate detection and response cache
+// https://tools.ietf.org/html/rfc5080#section-2.2.2
+package radius
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	added time.Time
+	res   []byte // nil while in-flight
+}
+
+// Responses by client+port+Identifier+Request Authenticator
+type cache struct {
+	window  time.Duration
+	lock    sync.Mutex
+	entries map[string]*cacheEntry
+	sweep   time.Time
+}
+
+func newCache(window time.Duration) *cache {
+	return &cache{
+		window:  window,
+		entries: make(map[string]*cacheEntry),
+		sweep:   time.Now(),
+	}
+}
+
+// Key for the request, empty if the packet is too short to tell
+func cacheKey(client net.Addr, raw []byte) string {
+	if len(raw) < 20 {
+		return ""
+	}
+	return fmt.Sprintf("%s-%d-%x", client.String(), raw[1], raw[4:20])
+}
+
+// Reserve key for a new request, if it's a duplicate return
+// the response sent earlier (nil when still in-flight).
+func (c *cache) begin(key string) (res []byte, dup bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.sweep) > c.window {
+		c.expire(now)
+	}
+
+	if entry, ok := c.entries[key]; ok {
+		return entry.res, true
+	}
+	c.entries[key] = &cacheEntry{added: now}
+	return nil, false
+}
+
+// Store response to replay on retransmits
+func (c *cache) finish(key string, res []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if entry, ok := c.entries[key]; ok {
+		entry.res = res
+		entry.added = time.Now()
+	}
+}
+
+// Nothing sent, a retransmit should be handled again
+func (c *cache) forget(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, key)
+}
+
+// Remove answered requests older than window, caller holds lock
+func (c *cache) expire(now time.Time) {
+	for key, entry := range c.entries {
+		if entry.res != nil && now.Sub(entry.added) > c.window {
+			delete(c.entries, key)
+		}
+	}
+	c.sweep = now
+}
@@ -0,0 +1,160 @@
+package radius
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := newCache(time.Minute)
+	if res, dup := c.begin("a"); res != nil || dup {
+		t.Fatal("New request is a duplicate")
+	}
+	// Retransmit while in-flight, nothing to replay yet
+	if res, dup := c.begin("a"); res != nil || !dup {
+		t.Fatal("In-flight retransmit not a duplicate")
+	}
+	c.finish("a", []byte("response"))
+	if res, dup := c.begin("a"); !dup || string(res) != "response" {
+		t.Fatalf("Expected response replay, found=%s", res)
+	}
+
+	// Nothing sent, retransmit is handled again
+	c.begin("b")
+	c.forget("b")
+	if _, dup := c.begin("b"); dup {
+		t.Fatal("Forgotten request is a duplicate")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	client := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1812}
+	a := validPacket()
+	b := append([]byte{}, a...)
+	copy(b[4:20], []byte("fedcba9876543210"))
+
+	// Same (src, id), other Request Authenticator is a new request
+	if cacheKey(client, a) != cacheKey(client, append([]byte{}, a...)) {
+		t.Fatal("Identical retransmit has another key")
+	}
+	if cacheKey(client, a) == cacheKey(client, b) {
+		t.Fatal("New Request Authenticator has the same key")
+	}
+	c := newCache(time.Minute)
+	c.begin(cacheKey(client, a))
+	if _, dup := c.begin(cacheKey(client, b)); dup {
+		t.Fatal("New Request Authenticator is a duplicate")
+	}
+	if cacheKey(client, a[:19]) != "" {
+		t.Fatal("Short packet has a key")
+	}
+}
+
+func TestCacheExpire(t *testing.T) {
+	c := newCache(10 * time.Millisecond)
+	c.begin("answered")
+	c.finish("answered", []byte("response"))
+	c.begin("in-flight")
+
+	time.Sleep(20 * time.Millisecond)
+	// Swept on the next begin
+	c.begin("other")
+	if _, dup := c.begin("answered"); dup {
+		t.Fatal("Answered request not expired")
+	}
+	if _, dup := c.begin("in-flight"); !dup {
+		t.Fatal("In-flight request expired")
+	}
+}
+
+var testAcct struct {
+	once sync.Once
+	lock sync.Mutex
+	fn   func(io.Writer, *Packet)
+}
+
+// Accounting-Request Start calls fn, for the Serve tests
+func testAcctHandler(fn func(io.Writer, *Packet)) {
+	testAcct.once.Do(func() {
+		HandleFunc(AccountingRequest, 1, func(w io.Writer, p *Packet) {
+			testAcct.lock.Lock()
+			fn := testAcct.fn
+			testAcct.lock.Unlock()
+			fn(w, p)
+		})
+	})
+	testAcct.lock.Lock()
+	testAcct.fn = fn
+	testAcct.lock.Unlock()
+}
+
+// Serve srv on loopback, close the returned conn to stop
+func testUDP(t *testing.T, srv *Server) *net.UDPConn {
+	conn, e := Listen("127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	srv.Secret = "secret"
+	srv.CIDR = []string{"127.0.0.1/32"}
+	srv.Logger = testLogger()
+	go srv.Serve(conn)
+	return conn
+}
+
+func acctStart() *Packet {
+	return NewRequest(AccountingRequest, "secret", []AttrEncoder{
+		NewAttr(AcctStatusType, EncodeFour(1), 0),
+		NewAttr(UserName, []byte("user"), 0),
+	})
+}
+
+// Send raw and wait for the response
+func testSend(t *testing.T, conn net.Conn, raw []byte) []byte {
+	if _, e := conn.Write(raw); e != nil {
+		t.Fatal(e)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, MaxPacketLen)
+	n, e := conn.Read(buf)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return buf[:n]
+}
+
+func TestServeRetransmit(t *testing.T) {
+	var calls uint32
+	testAcctHandler(func(w io.Writer, p *Packet) {
+		atomic.AddUint32(&calls, 1)
+		w.Write(p.Response(AccountingResponse, nil, false, nil))
+	})
+	ln := testUDP(t, &Server{Acct: true})
+	defer ln.Close()
+
+	conn, e := net.Dial("udp", ln.LocalAddr().String())
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer conn.Close()
+
+	_, before := Statistics()
+	req := acctStart()
+	raw := req.Request(false, nil)
+	first := testSend(t, conn, raw)
+	second := testSend(t, conn, raw)
+	if !validResponse(first, req) || !bytes.Equal(first, second) {
+		t.Fatal("Retransmit not answered with the cached response")
+	}
+	if n := atomic.LoadUint32(&calls); n != 1 {
+		t.Fatalf("Retransmit handled %d times", n)
+	}
+	_, after := Statistics()
+	if after.Requests != before.Requests+1 || after.Duplicate != before.Duplicate+1 {
+		t.Fatalf("Expected 1 request and 1 duplicate, found=%d and %d", after.Requests-before.Requests, after.Duplicate-before.Duplicate)
+	}
+}
@@ -0,0 +1,105 @@
+// Client-side to push requests to a NAS
+// https://tools.ietf.org/html/rfc5176
+package radius
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+var lastIdentifier uint32
+
+// Create request packet signed with secret, Identifier is
+// picked from a global counter. Access-Request gets a random
+// Request Authenticator (for User-Password), others are set by Request.
+func NewRequest(code PacketCode, secret string, attrs []AttrEncoder) *Packet {
+	p := &Packet{
+		secret:     secret,
+		Code:       code,
+		Identifier: uint8(atomic.AddUint32(&lastIdentifier, 1)),
+		Auth:       make([]byte, 16), // Set by Encode
+	}
+	if code == AccessRequest {
+		if _, e := rand.Read(p.Auth); e != nil {
+			panic(e)
+		}
+	}
+	for _, attr := range attrs {
+		p.Attrs = append(p.Attrs, NewAttr(attr.Type(), attr.Bytes(), uint8(2+len(attr.Bytes()))))
+	}
+	return p
+}
+
+// Encode request and set Request Authenticator
+// MD5(Code+ID+Length+16 zero octets+Attributes+Secret)
+// Access-Request keeps the random one from NewRequest (RFC2865 3)
+func (p *Packet) Request(verbose bool, logger *log.Logger) []byte {
+	if p.Code == AccessRequest {
+		return encode(p, verbose, logger)
+	}
+	p.Auth = make([]byte, 16)
+	r := encode(p, verbose, logger)
+
+	h := md5.New()
+	h.Write(r)
+	h.Write([]byte(p.secret))
+	copy(p.Auth, h.Sum(nil)[:16])
+	copy(r[4:20], p.Auth)
+	return r
+}
+
+// Send request to addr and wait for the signed reply.
+func Exchange(addr string, p *Packet, timeout time.Duration, verbose bool, logger *log.Logger) (*Packet, error) {
+	conn, e := net.Dial("udp", addr)
+	if e != nil {
+		return nil, e
+	}
+	defer conn.Close()
+
+	raw := p.Request(verbose, logger)
+	if verbose {
+		logger.Printf("raw.send: %+v", raw)
+	}
+	if e := conn.SetDeadline(time.Now().Add(timeout)); e != nil {
+		return nil, e
+	}
+	if _, e := conn.Write(raw); e != nil {
+		return nil, e
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, e := conn.Read(buf)
+		if e != nil {
+			return nil, e
+		}
+		if verbose {
+			logger.Printf("raw.recv: %+v", buf[:n])
+		}
+		if n < 20 || buf[1] != p.Identifier {
+			// Not our answer, keep waiting
+			continue
+		}
+		if !validResponse(buf[:n], p) {
+			return nil, fmt.Errorf("Invalid Response Authenticator from %s", addr)
+		}
+		return decode(buf, n, p.secret, verbose, logger)
+	}
+}
+
+// Response Authenticator matches our request
+// MD5(Code+ID+Length+RequestAuth+Attributes+Secret)
+func validResponse(raw []byte, req *Packet) bool {
+	h := md5.New()
+	h.Write(raw[0:4])
+	h.Write(req.Auth)
+	h.Write(raw[20:])
+	h.Write([]byte(req.secret))
+	return hmac.Equal(raw[4:20], h.Sum(nil))
+}
@@ -0,0 +1,35 @@
+package radius
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRequestAccessAuth(t *testing.T) {
+	a := NewRequest(AccessRequest, "secret", nil)
+	b := NewRequest(AccessRequest, "secret", nil)
+	if bytes.Equal(a.Auth, make([]byte, 16)) || bytes.Equal(a.Auth, b.Auth) {
+		t.Fatal("Access-Request Authenticator not random")
+	}
+
+	// Sent as is, the server decrypts User-Password with it
+	a.Attrs = append(a.Attrs, NewAttr(UserPassword, EncryptPassword("derp", a), 0))
+	raw := a.Request(false, nil)
+	if !bytes.Equal(raw[4:20], a.Auth) {
+		t.Fatal("Access-Request Authenticator changed by Request")
+	}
+	p, e := decode(raw, len(raw), "secret", false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if pass := DecryptPassword(p.Attr(UserPassword), p); pass != "derp" {
+		t.Fatalf("User-Password mismatch, found=%s", pass)
+	}
+
+	// Other requests sign the packet instead
+	acct := NewRequest(AccountingRequest, "secret", nil)
+	raw = acct.Request(false, nil)
+	if bytes.Equal(raw[4:20], make([]byte, 16)) {
+		t.Fatal("Accounting-Request Authenticator not set")
+	}
+}
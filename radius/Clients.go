@@ -0,0 +1,85 @@
+package radius
+
+import (
+	"net"
+	"sort"
+	"sync"
+)
+
+// NAS allowed to send us requests
+type Client struct {
+	Name   string
+	Net    *net.IPNet
+	Secret string
+	Type   string // NAS type (i.e. mikrotik)
+
+	RequireMessageAuthenticator bool // Drop Access-Requests without it
+}
+
+// Client lookup by IP (longest prefix wins)
+type Clients struct {
+	lock sync.RWMutex
+	list []*Client // Sorted by prefix length, longest first
+}
+
+// Parse CIDR and create client
+func NewClient(name string, cidr string, secret string, nasType string) (*Client, error) {
+	_, n, e := net.ParseCIDR(cidr)
+	if e != nil {
+		return nil, e
+	}
+	return &Client{Name: name, Net: n, Secret: secret, Type: nasType}, nil
+}
+
+func NewClients(list []*Client) *Clients {
+	c := new(Clients)
+	c.Replace(list)
+	return c
+}
+
+// Swap all clients (i.e. after reloading the nas-table)
+func (c *Clients) Replace(list []*Client) {
+	sorted := make([]*Client, len(list))
+	copy(sorted, list)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, _ := sorted[i].Net.Mask.Size()
+		b, _ := sorted[j].Net.Mask.Size()
+		return a > b
+	})
+
+	c.lock.Lock()
+	c.list = sorted
+	c.lock.Unlock()
+}
+
+// Most specific client for ip, nil if none
+func (c *Clients) Match(ip net.IP) *Client {
+	if c == nil {
+		return nil
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, client := range c.list {
+		if client.Net.Contains(ip) {
+			return client
+		}
+	}
+	return nil
+}
+
+// Client by name, nil if none
+func (c *Clients) ByName(name string) *Client {
+	if c == nil || name == "" {
+		return nil
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, client := range c.list {
+		if client.Name == name {
+			return client
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package radius
+
+import (
+	"net"
+	"testing"
+)
+
+func TestServerMatch(t *testing.T) {
+	nas1, _ := NewClient("nas1", "10.0.0.0/8", "secret1", "")
+	nas2, _ := NewClient("nas2", "10.1.1.1/32", "secret2", "")
+	srv := &Server{Clients: NewClients([]*Client{nas1, nas2}), Secret: "secret", CIDR: []string{"10.1.0.0/16", "10.0.0.0/8"}}
+	whitelist, e := srv.whitelist()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	tests := map[string]string{
+		"10.1.1.1": "secret2", // NAS table /32
+		"10.1.2.3": "secret",  // listen.CIDR /16 over NAS table /8
+		"10.2.0.1": "secret1", // Equal length, NAS table wins
+	}
+	for ip, secret := range tests {
+		c := srv.match(net.ParseIP(ip), whitelist)
+		if c == nil || c.Secret != secret {
+			t.Errorf("%s: expected secret=%s, found=%+v", ip, secret, c)
+		}
+	}
+	if srv.match(net.ParseIP("192.168.1.1"), whitelist) != nil {
+		t.Error("Unknown IP matched")
+	}
+}
@@ -15,10 +15,13 @@ import (
 	"encoding/binary"
 	"fmt"
 	"log"
+
+	"github.com/pkg/errors"
 )
 
 type Packet struct {
-	secret     string // shared secret
+	secret     string  // shared secret
+	client     *Client // NAS we received the packet from
 	Code       PacketCode
 	Identifier uint8
 	Len        uint16
@@ -30,6 +33,22 @@ func (p *Packet) Secret() string {
 	return p.secret
 }
 
+// Name of the NAS we received the packet from
+func (p *Packet) ClientName() string {
+	if p.client == nil {
+		return ""
+	}
+	return p.client.Name
+}
+
+// Type of the NAS we received the packet from
+func (p *Packet) ClientType() string {
+	if p.client == nil {
+		return ""
+	}
+	return p.client.Type
+}
+
 // Get first packet by key
 func (p *Packet) Attr(key AttributeType) []byte {
 	for _, a := range p.Attrs {
@@ -50,14 +69,37 @@ func (p *Packet) HasAttr(key AttributeType) bool {
 	return false
 }
 
+// Maximum packet size (RFC2865 section 3)
+const MaxPacketLen = 4096
+
+var (
+	ErrPacketTooShort = errors.New("packet shorter than header")
+	ErrPacketLen      = errors.New("packet Length invalid")
+	ErrAttrLen        = errors.New("attribute Length invalid")
+)
+
 // Decode bytes into packet
 func decode(buf []byte, n int, secret string, verbose bool, logger *log.Logger) (*Packet, error) {
+	if n > len(buf) {
+		n = len(buf)
+	}
+	if n < 20 {
+		return nil, ErrPacketTooShort
+	}
 	p := &Packet{}
 	p.secret = secret
 	p.Code = PacketCode(buf[0])
 	p.Identifier = buf[1]
 	p.Len = binary.BigEndian.Uint16(buf[2:4])
 
+	// If the packet is shorter than the Length field indicates, it MUST
+	// be silently discarded. Octets outside the range of the Length
+	// field MUST be treated as padding and ignored on reception.
+	if p.Len < 20 || p.Len > MaxPacketLen || int(p.Len) > n {
+		return nil, errors.Wrapf(ErrPacketLen, "len=%d received=%d", p.Len, n)
+	}
+	n = int(p.Len)
+
 	p.Auth = buf[4:20] // 16 octets
 
 	// attrs
@@ -66,10 +108,16 @@ func decode(buf []byte, n int, secret string, verbose bool, logger *log.Logger)
 		if i >= n {
 			break
 		}
+		if i+2 > n {
+			return nil, errors.Wrapf(ErrAttrLen, "offset=%d truncated header", i)
+		}
 
 		length := uint8(buf[i+1])
 		b := i + 2
 		e := b + int(length) - 2 // Length is including type+Length fields
+		if length < 2 || e > n {
+			return nil, errors.Wrapf(ErrAttrLen, "offset=%d length=%d", i, length)
+		}
 		attr := NewAttr(AttributeType(buf[i]), buf[b:e], length)
 		p.Attrs = append(p.Attrs, attr)
 
@@ -83,7 +131,7 @@ func decode(buf []byte, n int, secret string, verbose bool, logger *log.Logger)
 
 // Encode packet into bytes
 func encode(p *Packet, verbose bool, logger *log.Logger) []byte {
-	b := make([]byte, 1024)
+	b := make([]byte, MaxPacketLen)
 	b[0] = uint8(p.Code)
 	b[1] = p.Identifier
 	// Skip Len for now 2+3
@@ -96,6 +144,9 @@ func encode(p *Packet, verbose bool, logger *log.Logger) []byte {
 		if aLen > 255 || aLen < 2 {
 			panic("Value too big for attr")
 		}
+		if aLen > len(bb) {
+			panic("Value too big for packet")
+		}
 		bb[0] = uint8(attr.Type())
 		bb[1] = uint8(aLen)
 		copy(bb[2:], attr.Bytes())
@@ -115,22 +166,73 @@ func encode(p *Packet, verbose bool, logger *log.Logger) []byte {
 // MessageAuthenticate if any
 func validate(p *Packet, verbose bool, logger *log.Logger) bool {
 	if p.HasAttr(MessageAuthenticator) {
-		check := p.Attr(MessageAuthenticator)
-		h := md5.New()
-		temp := encode(p, verbose, logger)
-		//h.Write(temp[0:4])
-		//h.Write([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
-		//h.Write(temp[20:])
-		h.Write(temp)
-		h.Write([]byte(p.secret))
-
-		if !hmac.Equal(check, h.Sum(nil)) {
-			return false
-		}
+		return validMessageAuthenticator(p)
 	}
 	return true
 }
 
+// Access-Request without Message-Authenticator from a NAS that must send it
+// https://tools.ietf.org/html/draft-ietf-radext-deprecating-radius
+func missingMessageAuthenticator(p *Packet) bool {
+	if p.client == nil || !p.client.RequireMessageAuthenticator {
+		return false
+	}
+	return p.Code == AccessRequest && !p.HasAttr(MessageAuthenticator)
+}
+
+// Offset of the value of the first attribute of type key
+// in the encoded packet, -1 if not found.
+func attrOffset(raw []byte, key AttributeType) int {
+	i := 20
+	for i+2 <= len(raw) {
+		length := int(raw[i+1])
+		if length < 2 {
+			break
+		}
+		if AttributeType(raw[i]) == key {
+			return i + 2
+		}
+		i += length
+	}
+	return -1
+}
+
+// HMAC-MD5 over the encoded packet (with a zeroed Message-Authenticator)
+// https://tools.ietf.org/html/rfc3579#section-3.2
+func messageAuthenticator(raw []byte, secret string) []byte {
+	h := hmac.New(md5.New, []byte(secret))
+	h.Write(raw)
+	return h.Sum(nil)
+}
+
+// Message-Authenticator present and matching
+func validMessageAuthenticator(p *Packet) bool {
+	if !p.HasAttr(MessageAuthenticator) {
+		return false
+	}
+	raw := encode(p, false, nil)
+	i := attrOffset(raw, MessageAuthenticator)
+	if i == -1 || i+16 > len(raw) {
+		return false
+	}
+	check := make([]byte, 16)
+	copy(check, raw[i:i+16])
+	copy(raw[i:i+16], make([]byte, 16))
+	if p.Code == AccountingRequest {
+		// RFC3579 section 3.2 only covers Access-*, the Request
+		// Authenticator is a hash over the packet so it's zeroed
+		// too, as for Disconnect/CoA (RFC5176 section 3.3)
+		copy(raw[4:20], make([]byte, 16))
+	}
+
+	return hmac.Equal(check, messageAuthenticator(raw, p.secret))
+}
+
+// Always sign Access-responses (BlastRADIUS, CVE-2024-3596)
+func signResponse(code PacketCode) bool {
+	return code == AccessAccept || code == AccessReject || code == AccessChallenge
+}
+
 // Create response packet
 func (p *Packet) Response(code PacketCode, attrs []AttrEncoder, verbose bool, logger *log.Logger) []byte {
 	n := &Packet{
@@ -145,10 +247,20 @@ func (p *Packet) Response(code PacketCode, attrs []AttrEncoder, verbose bool, lo
 		msg := NewAttr(attr.Type(), attr.Bytes(), uint8(2+len(attr.Bytes())))
 		n.Attrs = append(n.Attrs, msg)
 	}
+	if signResponse(code) && !n.HasAttr(MessageAuthenticator) {
+		// Placeholder, signed below
+		n.Attrs = append(n.Attrs, NewAttr(MessageAuthenticator, make([]byte, 16), 18))
+	}
 
 	// Encode
 	r := encode(n, verbose, logger)
 
+	// Sign Message-Authenticator placeholder (if any) before
+	// the Response Authenticator is calculated
+	if i := attrOffset(r, MessageAuthenticator); i != -1 {
+		copy(r[i:i+16], messageAuthenticator(r, p.secret))
+	}
+
 	// Set right Response Authenticator
 	// MD5(Code+ID+Length+RequestAuth+Attributes+Secret)
 	h := md5.New()
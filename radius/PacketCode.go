@@ -12,5 +12,12 @@ const (
 	AccessChallenge    PacketCode = 11
 	StatusServer       PacketCode = 12 //(experimental)
 	StatusClient       PacketCode = 13 //(experimental)
-	Reserved           PacketCode = 255
+	// https://tools.ietf.org/html/rfc5176
+	DisconnectRequest PacketCode = 40
+	DisconnectACK     PacketCode = 41
+	DisconnectNAK     PacketCode = 42
+	CoARequest        PacketCode = 43
+	CoAACK            PacketCode = 44
+	CoANAK            PacketCode = 45
+	Reserved          PacketCode = 255
 )
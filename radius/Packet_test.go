@@ -0,0 +1,203 @@
+package radius
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func validPacket() []byte {
+	p := &Packet{
+		Code:       AccessRequest,
+		Identifier: 1,
+		Auth:       []byte("0123456789abcdef"),
+		Attrs: []AttrEncoder{
+			NewAttr(UserName, []byte("user"), 0),
+			NewAttr(NASIPAddress, []byte{127, 0, 0, 1}, 0),
+			NewAttr(NASPort, EncodeFour(33), 0),
+		},
+	}
+	return encode(p, false, nil)
+}
+
+func TestDecodeValid(t *testing.T) {
+	raw := validPacket()
+	p, e := decode(raw, len(raw), "secret", false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(p.Attrs) != 3 {
+		t.Fatalf("Expected 3 attrs, found=%d", len(p.Attrs))
+	}
+	if string(p.Attr(UserName)) != "user" {
+		t.Fatalf("UserName wrong, found=%s", p.Attr(UserName))
+	}
+}
+
+func TestDecodePadding(t *testing.T) {
+	// Octets beyond Length are padding
+	raw := append(validPacket(), 0xff, 0xff, 0xff)
+	p, e := decode(raw, len(raw), "secret", false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(p.Attrs) != 3 {
+		t.Fatalf("Expected 3 attrs, found=%d", len(p.Attrs))
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	valid := validPacket()
+	tests := map[string]func([]byte) []byte{
+		"short header": func(b []byte) []byte {
+			return b[:19]
+		},
+		"len below header": func(b []byte) []byte {
+			binary.BigEndian.PutUint16(b[2:4], 19)
+			return b
+		},
+		"len above received": func(b []byte) []byte {
+			binary.BigEndian.PutUint16(b[2:4], uint16(len(b)+1))
+			return b
+		},
+		"len above max": func(b []byte) []byte {
+			binary.BigEndian.PutUint16(b[2:4], MaxPacketLen+1)
+			return b
+		},
+		"attr len zero": func(b []byte) []byte {
+			b[21] = 0
+			return b
+		},
+		"attr len one": func(b []byte) []byte {
+			b[21] = 1
+			return b
+		},
+		"attr beyond len": func(b []byte) []byte {
+			b[21] = 255
+			return b
+		},
+		"attr header truncated": func(b []byte) []byte {
+			b = append(b, byte(ReplyMessage))
+			binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+			return b
+		},
+	}
+	for name, mutate := range tests {
+		raw := mutate(append([]byte{}, valid...))
+		if _, e := decode(raw, len(raw), "secret", false, nil); e == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(validPacket())
+	f.Add([]byte{})
+	f.Add(make([]byte, 20))
+	f.Add(append(validPacket(), 1, 2, 3))
+
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		p, e := decode(raw, len(raw), "secret", false, nil)
+		if e != nil {
+			return
+		}
+		if int(p.Len) > len(raw) || p.Len < 20 {
+			t.Fatalf("Len=%d accepted for %d octets", p.Len, len(raw))
+		}
+		sum := 20
+		for _, attr := range p.Attrs {
+			if int(attr.Length()) != len(attr.Bytes())+2 {
+				t.Fatalf("Attr length=%d mismatch with value=%d", attr.Length(), len(attr.Bytes()))
+			}
+			sum += int(attr.Length())
+		}
+		if sum != int(p.Len) {
+			t.Fatalf("Attrs cover %d octets, Len=%d", sum, p.Len)
+		}
+
+		// Decoded packets must survive encode
+		again := encode(p, false, nil)
+		if !bytes.Equal(again[:4], raw[:4]) || !bytes.Equal(again[20:], raw[20:p.Len]) {
+			t.Fatalf("Re-encode mismatch")
+		}
+	})
+}
+
+func TestResponseMessageAuthenticator(t *testing.T) {
+	req, e := decode(validPacket(), len(validPacket()), "secret", false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, code := range []PacketCode{AccessAccept, AccessReject, AccessChallenge} {
+		raw := req.Response(code, nil, false, nil)
+		i := attrOffset(raw, MessageAuthenticator)
+		if i == -1 {
+			t.Fatalf("%s: no Message-Authenticator", code)
+		}
+
+		// Verify over the response with the Request Authenticator
+		check := append([]byte{}, raw[i:i+16]...)
+		copy(raw[4:20], req.Auth)
+		copy(raw[i:i+16], make([]byte, 16))
+		if !bytes.Equal(check, messageAuthenticator(raw, "secret")) {
+			t.Fatalf("%s: Message-Authenticator invalid", code)
+		}
+	}
+}
+
+func TestValidateMessageAuthenticator(t *testing.T) {
+	raw := encode(&Packet{
+		Code:       AccessRequest,
+		Identifier: 1,
+		Auth:       []byte("0123456789abcdef"),
+		Attrs: []AttrEncoder{
+			NewAttr(UserName, []byte("user"), 0),
+			NewAttr(MessageAuthenticator, make([]byte, 16), 0),
+		},
+	}, false, nil)
+	i := attrOffset(raw, MessageAuthenticator)
+	copy(raw[i:i+16], messageAuthenticator(raw, "secret"))
+
+	p, e := decode(raw, len(raw), "secret", false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !validate(p, false, nil) {
+		t.Fatal("Valid Message-Authenticator rejected")
+	}
+
+	p.Attrs[0] = NewAttr(UserName, []byte("evil"), 0)
+	if validate(p, false, nil) {
+		t.Fatal("Tampered packet accepted")
+	}
+
+	p.Attrs = p.Attrs[:1]
+	p.client = &Client{RequireMessageAuthenticator: true}
+	if !missingMessageAuthenticator(p) {
+		t.Fatal("Missing Message-Authenticator accepted")
+	}
+}
+
+func TestValidateMessageAuthenticatorAcct(t *testing.T) {
+	raw := encode(&Packet{
+		Code:       AccountingRequest,
+		Identifier: 1,
+		Auth:       make([]byte, 16),
+		Attrs: []AttrEncoder{
+			NewAttr(UserName, []byte("user"), 0),
+			NewAttr(MessageAuthenticator, make([]byte, 16), 0),
+		},
+	}, false, nil)
+	// Signed with zero Request Authenticator, then set the real one
+	i := attrOffset(raw, MessageAuthenticator)
+	copy(raw[i:i+16], messageAuthenticator(raw, "secret"))
+	copy(raw[4:20], []byte("0123456789abcdef"))
+
+	p, e := decode(raw, len(raw), "secret", false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !validate(p, false, nil) {
+		t.Fatal("Valid Accounting-Request Message-Authenticator rejected")
+	}
+}
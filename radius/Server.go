@@ -8,6 +8,8 @@ import (
 	"io"
 	"log"
 	"net"
+	"sync"
+	"time"
 )
 
 var handlers map[string]func(io.Writer, *Packet)
@@ -32,74 +34,320 @@ func Listen(addr string) (*net.UDPConn, error) {
 	return net.ListenUDP("udp", udpAddr)
 }
 
-func Serve(conn *net.UDPConn, secret string, cidrs []string, verbose bool, logger *log.Logger) error {
-	var whitelist []*net.IPNet
+const (
+	DefaultWorkers   = 16
+	DefaultQueueSize = 256
+	DefaultTimeout   = 5 * time.Second
+	DefaultDupWindow = 10 * time.Second
+)
+
+// Settings for one listener
+type Server struct {
+	Clients   *Clients // NAS table, checked before Secret+CIDR
+	Secret    string   // Listener secret for NAS in CIDR
+	CIDR      []string
+	Acct      bool          // Accounting listener (Status-Server answered with Accounting-Response)
+	Workers   int           // Concurrent handlers (DefaultWorkers if 0)
+	QueueSize int           // Packets waiting for a worker (DefaultQueueSize if 0)
+	Timeout   time.Duration // Max time queued before handling (DefaultTimeout if 0)
+	DupWindow time.Duration // Replay responses to retransmits (DefaultDupWindow if 0, disabled if <0)
+
+	// TLS/TCP only
+	IdleTimeout time.Duration // Close connection without requests (DefaultIdleTimeout if 0)
+	MaxInFlight int           // Concurrent requests per connection (DefaultMaxInFlight if 0)
+
+	Verbose bool
+	Logger  *log.Logger
+}
+
+// Received packet waiting for a worker
+type job struct {
+	buf      []byte
+	client   net.Addr
+	ip       net.IP
+	nas      *Client
+	deadline time.Time
+	key      string             // Duplicate detection, empty if not cached
+	reply    func([]byte) error // Send response to client
+}
+
+// Counters to report to, by packet code if known
+func (s *Server) stats(code PacketCode) *Stats {
+	switch code {
+	case AccessRequest:
+		return &authStats
+	case AccountingRequest:
+		return &acctStats
+	}
+	if s.Acct {
+		return &acctStats
+	}
+	return &authStats
+}
 
-	for _, cidr := range cidrs {
-		_, net, e := net.ParseCIDR(cidr)
+// Clients by listener CIDR+Secret
+func (s *Server) whitelist() (*Clients, error) {
+	var list []*Client
+	for _, cidr := range s.CIDR {
+		client, e := NewClient(cidr, cidr, s.Secret, "")
 		if e != nil {
-			return e
+			return nil, e
 		}
-		whitelist = append(whitelist, net)
+		list = append(list, client)
+	}
+	return NewClients(list), nil
+}
+
+// Longest prefix of the NAS table and listener CIDR, the NAS
+// table wins on equal length
+func (s *Server) match(ip net.IP, whitelist *Clients) *Client {
+	nas := s.Clients.Match(ip)
+	cidr := whitelist.Match(ip)
+	if nas == nil || cidr == nil {
+		if nas != nil {
+			return nas
+		}
+		return cidr
+	}
+	a, _ := nas.Net.Mask.Size()
+	b, _ := cidr.Net.Mask.Size()
+	if b > a {
+		return cidr
+	}
+	return nas
+}
+
+func (s *Server) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return s.Timeout
+}
+
+// Read packets and dispatch them to the workers, returns
+// when conn is closed after all queued packets are handled.
+func (s *Server) Serve(conn *net.UDPConn) error {
+	whitelist, e := s.whitelist()
+	if e != nil {
+		return e
+	}
+
+	workers := s.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	queueSize := s.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	var dups *cache
+	if s.DupWindow == 0 {
+		dups = newCache(DefaultDupWindow)
+	} else if s.DupWindow > 0 {
+		dups = newCache(s.DupWindow)
 	}
 
-	buf := make([]byte, 1024)
-	readBuf := new(bytes.Buffer)
+	jobs := make(chan job, queueSize)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				res := s.process(j)
+				if j.key == "" {
+					continue
+				}
+				if res == nil {
+					dups.forget(j.key)
+				} else {
+					dups.finish(j.key, res)
+				}
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(jobs)
+
 	for {
+		buf := make([]byte, MaxPacketLen)
 		n, client, e := conn.ReadFromUDP(buf)
 		if e != nil {
-			// TODO: Silently ignore?
+			// Socket closed
 			return e
 		}
-		ok := false
-		for _, cidr := range whitelist {
-			if cidr.Contains(client.IP) {
-				ok = true
-				break
-			}
+		code := PacketCode(0)
+		if n > 0 {
+			code = PacketCode(buf[0])
 		}
-		if !ok {
-			logger.Printf("Request dropped for invalid IP=" + client.String())
+		stats := s.stats(code)
+
+		nas := s.match(client.IP, whitelist)
+		if nas == nil {
+			s.Logger.Printf("Request dropped for invalid IP=" + client.String())
+			incr(&stats.Invalid)
 			continue
 		}
 
-		if verbose {
-			logger.Printf("raw.recv: %+v", buf[:n])
+		j := job{
+			buf:      buf[:n],
+			client:   client,
+			ip:       client.IP,
+			nas:      nas,
+			deadline: time.Now().Add(s.timeout()),
+			reply: func(b []byte) error {
+				_, e := conn.WriteTo(b, client)
+				return e
+			},
 		}
-		p, e := decode(buf, n, secret, verbose, logger)
-		if e != nil {
-			// TODO: Silently ignore decode?
-			return e
+		if dups != nil && code != StatusServer {
+			j.key = cacheKey(client, j.buf)
 		}
-		if !validate(p, verbose, logger) {
-			// TODO: Silently ignore invalidate package?
-			return fmt.Errorf("Invalid MessageAuthenticator")
+		if j.key != "" {
+			res, dup := dups.begin(j.key)
+			if dup {
+				incr(&stats.Duplicate)
+				incr(&clientCounters(client.IP.String()).Duplicate)
+				if s.Verbose {
+					s.Logger.Printf("Duplicate request IP=%s ident=%d replay=%t", client.String(), buf[1], res != nil)
+				}
+				if res != nil {
+					s.write(j, res)
+				}
+				continue
+			}
 		}
 
-		statusType := uint32(0)
-		if p.HasAttr(AcctStatusType) {
-			attr := p.Attr(AcctStatusType)
+		select {
+		case jobs <- j:
+		default:
+			// Back-pressure, NAS will retransmit
+			if j.key != "" {
+				dups.forget(j.key)
+			}
+			incr(&stats.Dropped)
+			incr(&clientCounters(client.IP.String()).Dropped)
+			s.Logger.Printf("Request dropped for full queue IP=" + client.String())
+		}
+	}
+}
+
+// Decode, validate and handle one packet, returns the
+// response sent (if any).
+func (s *Server) process(j job) []byte {
+	client := j.client
+	stats := s.stats(0)
+	if len(j.buf) > 0 {
+		stats = s.stats(PacketCode(j.buf[0]))
+	}
+	counters := clientCounters(j.ip.String())
+
+	if time.Now().After(j.deadline) {
+		// Waited too long in queue, NAS already gave up on us
+		incr(&stats.Dropped)
+		incr(&counters.Dropped)
+		s.Logger.Printf("Request dropped for deadline in queue IP=" + client.String())
+		return nil
+	}
+
+	if s.Verbose {
+		s.Logger.Printf("raw.recv: %+v", j.buf)
+	}
+	p, e := decode(j.buf, len(j.buf), j.nas.Secret, s.Verbose, s.Logger)
+	if e != nil {
+		// Silently discard (RFC2865 section 3)
+		incr(&stats.Malformed)
+		incr(&counters.Malformed)
+		s.Logger.Printf("Request dropped for malformed packet IP=%s nas=%s e=%s", client.String(), j.nas.Name, e.Error())
+		return nil
+	}
+	p.client = j.nas
+	if p.Code == StatusServer {
+		// Built-in, no handler needed
+		res := s.statusServer(p)
+		if len(res) == 0 {
+			incr(&counters.Invalid)
+			return nil
+		}
+		s.write(j, res)
+		return res
+	}
+	if !validate(p, s.Verbose, s.Logger) {
+		// Silently discard (RFC3579 section 3.2)
+		incr(&stats.Invalid)
+		incr(&counters.Invalid)
+		s.Logger.Printf("Request dropped for invalid Message-Authenticator IP=%s nas=%s", client.String(), j.nas.Name)
+		return nil
+	}
+	if missingMessageAuthenticator(p) {
+		incr(&stats.Invalid)
+		incr(&counters.Invalid)
+		s.Logger.Printf("Request dropped for missing Message-Authenticator IP=%s nas=%s", client.String(), j.nas.Name)
+		return nil
+	}
+	incr(&stats.Requests)
+	incr(&counters.Requests)
+
+	statusType := uint32(0)
+	if p.HasAttr(AcctStatusType) {
+		attr := p.Attr(AcctStatusType)
+		if len(attr) == 4 {
 			statusType = binary.BigEndian.Uint32(attr)
 		}
+	}
 
-		key := fmt.Sprintf("%d-%d", p.Code, statusType)
-		handle, ok := handlers[key]
-		if ok {
-			handle(readBuf, p)
-			if verbose {
-				logger.Printf("raw.send: %+v", readBuf.Bytes())
-			}
-			if len(readBuf.Bytes()) != 0 {
-				// Only send a packet if we got anything
-				if _, e := conn.WriteTo(readBuf.Bytes(), client); e != nil {
-					// TODO: ignore clients that gone away?
-					panic(e)
-				}
-			}
+	key := fmt.Sprintf("%d-%d", p.Code, statusType)
+	handle, ok := handlers[key]
+	if !ok {
+		if p.Code == AccessRequest || p.Code == AccountingRequest {
+			incr(&stats.Dropped)
 		} else {
-			fmt.Println(fmt.Sprintf("Drop packet with code=%d, statusType=%d", p.Code, statusType))
+			incr(&stats.UnknownTypes)
 		}
+		incr(&counters.Dropped)
+		s.Logger.Printf("Drop packet with code=%d, statusType=%d", p.Code, statusType)
+		return nil
+	}
+
+	w := new(bytes.Buffer)
+	if !s.handle(handle, w, p) {
+		incr(&stats.Dropped)
+		incr(&counters.Dropped)
+	}
+	if s.Verbose {
+		s.Logger.Printf("raw.send: %+v", w.Bytes())
+	}
+	if w.Len() == 0 {
+		// Only send a packet if we got anything
+		return nil
+	}
+	if time.Now().After(j.deadline) && s.Verbose {
+		// Side effects are stored already, send it anyway so the
+		// NAS doesn't retransmit and have it handled twice
+		s.Logger.Printf("Response late for deadline IP=%s code=%s", client.String(), p.Code)
+	}
+	stats.response(w.Bytes())
+	s.write(j, w.Bytes())
+	return w.Bytes()
+}
+
+// Call handler, a panicking handler only loses the packet
+func (s *Server) handle(handle func(io.Writer, *Packet), w *bytes.Buffer, p *Packet) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.Logger.Printf("WARN: handler panic code=%s e=%v", p.Code, r)
+			// Never send a half written response
+			w.Reset()
+			ok = false
+		}
+	}()
+	handle(w, p)
+	return true
+}
 
-		readBuf.Reset()
+func (s *Server) write(j job, b []byte) {
+	if e := j.reply(b); e != nil {
+		// Client gone away, nothing we can do
+		s.Logger.Printf("WARN: write to %s e=%s", j.client.String(), e.Error())
 	}
 }
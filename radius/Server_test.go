@@ -0,0 +1,109 @@
+package radius
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Handler blocking until release is closed, started gets a
+// value for every call
+func blockingAcct(started chan struct{}, release chan struct{}, calls *uint32) {
+	testAcctHandler(func(w io.Writer, p *Packet) {
+		atomic.AddUint32(calls, 1)
+		started <- struct{}{}
+		<-release
+		w.Write(p.Response(AccountingResponse, nil, false, nil))
+	})
+}
+
+func sendAcct(t *testing.T, conn net.Conn) {
+	if _, e := conn.Write(acctStart().Request(false, nil)); e != nil {
+		t.Fatal(e)
+	}
+}
+
+// Wait for the acct Dropped counter to reach n
+func waitDropped(t *testing.T, n uint64) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, acct := Statistics()
+		if acct.Dropped == n {
+			return
+		}
+		if acct.Dropped > n || time.Now().After(deadline) {
+			t.Fatalf("Expected %d dropped, found=%d", n, acct.Dropped)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Read responses until none arrives within timeout
+func readAll(conn net.Conn, timeout time.Duration) int {
+	count := 0
+	buf := make([]byte, MaxPacketLen)
+	for {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		if _, e := conn.Read(buf); e != nil {
+			return count
+		}
+		count++
+	}
+}
+
+func TestServeQueueFull(t *testing.T) {
+	started, release := make(chan struct{}, 8), make(chan struct{})
+	var calls uint32
+	blockingAcct(started, release, &calls)
+	ln := testUDP(t, &Server{Acct: true, Workers: 1, QueueSize: 1, DupWindow: -1, Timeout: time.Minute})
+	defer ln.Close()
+	conn := dialUDP(t, ln)
+	defer conn.Close()
+
+	_, before := Statistics()
+	sendAcct(t, conn)
+	<-started
+	// One queued, the rest dropped for back-pressure
+	for i := 0; i < 4; i++ {
+		sendAcct(t, conn)
+	}
+	waitDropped(t, before.Dropped+3)
+
+	close(release)
+	<-started
+	if n := readAll(conn, 200*time.Millisecond); n != 2 {
+		t.Fatalf("Expected 2 responses after unblocking, found=%d", n)
+	}
+	if n := atomic.LoadUint32(&calls); n != 2 {
+		t.Fatalf("Expected 2 handled, found=%d", n)
+	}
+}
+
+func TestServeDeadline(t *testing.T) {
+	started, release := make(chan struct{}, 8), make(chan struct{})
+	var calls uint32
+	blockingAcct(started, release, &calls)
+	ln := testUDP(t, &Server{Acct: true, Workers: 1, QueueSize: 4, DupWindow: -1, Timeout: 50 * time.Millisecond})
+	defer ln.Close()
+	conn := dialUDP(t, ln)
+	defer conn.Close()
+
+	_, before := Statistics()
+	sendAcct(t, conn)
+	<-started
+	sendAcct(t, conn)
+	// Queued longer than Timeout, NAS gave up on it
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	waitDropped(t, before.Dropped+1)
+	// First one finished after its deadline, still answered
+	if n := readAll(conn, 200*time.Millisecond); n != 1 {
+		t.Fatalf("Expected 1 response, found=%d", n)
+	}
+	if n := atomic.LoadUint32(&calls); n != 1 {
+		t.Fatalf("Expected 1 handled, found=%d", n)
+	}
+}
@@ -0,0 +1,96 @@
+package radius
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counters as reported by Status-Server
+type Stats struct {
+	Requests     uint64
+	Accepts      uint64
+	Rejects      uint64
+	Challenges   uint64
+	Responses    uint64
+	Duplicate    uint64
+	Malformed    uint64
+	Invalid      uint64
+	Dropped      uint64
+	UnknownTypes uint64
+}
+
+var (
+	authStats Stats
+	acctStats Stats
+	started   = time.Now()
+
+	// Per client IP
+	clientStats = make(map[string]*Stats)
+	clientLock  sync.Mutex
+)
+
+func incr(counter *uint64) {
+	atomic.AddUint64(counter, 1)
+}
+
+// Copy of the counters for auth and acct
+func Statistics() (auth Stats, acct Stats) {
+	return authStats.load(), acctStats.load()
+}
+
+// Copy of the counters per client IP
+func ClientStatistics() map[string]Stats {
+	clientLock.Lock()
+	defer clientLock.Unlock()
+
+	out := make(map[string]Stats, len(clientStats))
+	for ip, stats := range clientStats {
+		out[ip] = stats.load()
+	}
+	return out
+}
+
+// Counters for client IP
+func clientCounters(ip string) *Stats {
+	clientLock.Lock()
+	defer clientLock.Unlock()
+
+	stats, ok := clientStats[ip]
+	if !ok {
+		stats = new(Stats)
+		clientStats[ip] = stats
+	}
+	return stats
+}
+
+func (s *Stats) load() Stats {
+	return Stats{
+		Requests:     atomic.LoadUint64(&s.Requests),
+		Accepts:      atomic.LoadUint64(&s.Accepts),
+		Rejects:      atomic.LoadUint64(&s.Rejects),
+		Challenges:   atomic.LoadUint64(&s.Challenges),
+		Responses:    atomic.LoadUint64(&s.Responses),
+		Duplicate:    atomic.LoadUint64(&s.Duplicate),
+		Malformed:    atomic.LoadUint64(&s.Malformed),
+		Invalid:      atomic.LoadUint64(&s.Invalid),
+		Dropped:      atomic.LoadUint64(&s.Dropped),
+		UnknownTypes: atomic.LoadUint64(&s.UnknownTypes),
+	}
+}
+
+// Count the response we are about to send
+func (s *Stats) response(raw []byte) {
+	if len(raw) == 0 {
+		return
+	}
+	incr(&s.Responses)
+	switch PacketCode(raw[0]) {
+	case AccessAccept:
+		incr(&s.Accepts)
+	case AccessReject:
+		incr(&s.Rejects)
+	case AccessChallenge:
+		incr(&s.Challenges)
+	}
+}
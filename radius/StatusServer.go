@@ -0,0 +1,91 @@
+// Status-Server to let NAS/loadbalancers probe us
+// https://tools.ietf.org/html/rfc5997
+package radius
+
+import (
+	"github.com/mpdroog/radiusd/radius/vendor"
+)
+
+// Answer Status-Server without any handler, Access-Accept on auth
+// and Accounting-Response on acct listeners.
+func (s *Server) statusServer(p *Packet) []byte {
+	stats := s.stats(p.Code)
+	// Status-Server packets MUST contain a Message-Authenticator
+	if !validMessageAuthenticator(p) {
+		incr(&stats.Invalid)
+		if s.Verbose {
+			s.Logger.Printf("status.server dropped for invalid Message-Authenticator")
+		}
+		return nil
+	}
+
+	code := AccessAccept
+	if s.Acct {
+		code = AccountingResponse
+	}
+	reply := []AttrEncoder{NewAttr(MessageAuthenticator, make([]byte, 16), 0)}
+	if flags, ok := statisticsType(p); ok {
+		reply = append(reply, statistics(flags)...)
+	}
+	return p.Response(code, reply, s.Verbose, s.Logger)
+}
+
+// FreeRADIUS-Statistics-Type if requested
+func statisticsType(p *Packet) (uint32, bool) {
+	for _, attr := range p.Attrs {
+		if attr.Type() != VendorSpecific || len(attr.Bytes()) != 10 {
+			continue
+		}
+		hdr := VendorSpecificHeader(attr.Bytes())
+		if hdr.VendorId == vendor.FreeRADIUS && hdr.VendorType == uint8(vendor.FreeRADIUSStatisticsType) {
+			return DecodeFour(attr.Bytes()[6:10]), true
+		}
+	}
+	return 0, false
+}
+
+// Counters the FreeRADIUS way
+func statistics(flags uint32) []AttrEncoder {
+	auth, acct := Statistics()
+	counter := func(t vendor.AttributeType, n uint64) VendorAttrString {
+		return VendorAttrString{Type: t, Value: EncodeFour(uint32(n))}
+	}
+
+	out := VendorAttr{
+		Type:     VendorSpecific,
+		VendorId: vendor.FreeRADIUS,
+	}
+	if flags&vendor.FreeRADIUSStatsAuth != 0 {
+		out.Values = append(out.Values,
+			counter(vendor.FreeRADIUSTotalAccessRequests, auth.Requests),
+			counter(vendor.FreeRADIUSTotalAccessAccepts, auth.Accepts),
+			counter(vendor.FreeRADIUSTotalAccessRejects, auth.Rejects),
+			counter(vendor.FreeRADIUSTotalAccessChallenges, auth.Challenges),
+			counter(vendor.FreeRADIUSTotalAuthResponses, auth.Responses),
+			counter(vendor.FreeRADIUSTotalAuthDuplicate, auth.Duplicate),
+			counter(vendor.FreeRADIUSTotalAuthMalformed, auth.Malformed),
+			counter(vendor.FreeRADIUSTotalAuthInvalid, auth.Invalid),
+			counter(vendor.FreeRADIUSTotalAuthDropped, auth.Dropped),
+			counter(vendor.FreeRADIUSTotalAuthUnknownTypes, auth.UnknownTypes),
+		)
+	}
+	if flags&vendor.FreeRADIUSStatsAcct != 0 {
+		out.Values = append(out.Values,
+			counter(vendor.FreeRADIUSTotalAccountingReq, acct.Requests),
+			counter(vendor.FreeRADIUSTotalAccountingResp, acct.Responses),
+			counter(vendor.FreeRADIUSTotalAcctDuplicate, acct.Duplicate),
+			counter(vendor.FreeRADIUSTotalAcctMalformed, acct.Malformed),
+			counter(vendor.FreeRADIUSTotalAcctInvalid, acct.Invalid),
+			counter(vendor.FreeRADIUSTotalAcctDropped, acct.Dropped),
+			counter(vendor.FreeRADIUSTotalAcctUnknownTypes, acct.UnknownTypes),
+		)
+	}
+	if len(out.Values) == 0 {
+		return nil
+	}
+	out.Values = append(out.Values,
+		counter(vendor.FreeRADIUSStatsStartTime, uint64(started.Unix())),
+		counter(vendor.FreeRADIUSStatsHUPTime, uint64(started.Unix())),
+	)
+	return []AttrEncoder{out.Encode()}
+}
@@ -0,0 +1,142 @@
+package radius
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mpdroog/radiusd/radius/vendor"
+)
+
+// Status-Server signed with Message-Authenticator
+func statusRequest(attrs ...AttrEncoder) (*Packet, []byte) {
+	p := NewRequest(StatusServer, "secret", append(attrs, NewAttr(MessageAuthenticator, make([]byte, 16), 0)))
+	p.Auth = []byte("0123456789abcdef")
+	raw := encode(p, false, nil)
+	i := attrOffset(raw, MessageAuthenticator)
+	copy(raw[i:i+16], messageAuthenticator(raw, "secret"))
+	return p, raw
+}
+
+// Response Authenticator and Message-Authenticator of res are valid
+func validStatusResponse(res []byte, req *Packet) bool {
+	if !validResponse(res, req) {
+		return false
+	}
+	raw := append([]byte{}, res...)
+	i := attrOffset(raw, MessageAuthenticator)
+	if i == -1 {
+		return false
+	}
+	check := append([]byte{}, raw[i:i+16]...)
+	copy(raw[4:20], req.Auth)
+	copy(raw[i:i+16], make([]byte, 16))
+	return bytes.Equal(check, messageAuthenticator(raw, "secret"))
+}
+
+func dialUDP(t *testing.T, ln *net.UDPConn) net.Conn {
+	conn, e := net.Dial("udp", ln.LocalAddr().String())
+	if e != nil {
+		t.Fatal(e)
+	}
+	return conn
+}
+
+func TestStatusServer(t *testing.T) {
+	for _, acct := range []bool{false, true} {
+		ln := testUDP(t, &Server{Acct: acct})
+		conn := dialUDP(t, ln)
+
+		req, raw := statusRequest()
+		res := testSend(t, conn, raw)
+		expect := AccessAccept
+		if acct {
+			expect = AccountingResponse
+		}
+		if PacketCode(res[0]) != expect {
+			t.Fatalf("acct=%t: expected %s, found=%s", acct, expect, PacketCode(res[0]))
+		}
+		if !validStatusResponse(res, req) {
+			t.Fatalf("acct=%t: invalid response signature", acct)
+		}
+		conn.Close()
+		ln.Close()
+	}
+}
+
+func TestStatusServerInvalid(t *testing.T) {
+	ln := testUDP(t, &Server{})
+	defer ln.Close()
+	conn := dialUDP(t, ln)
+	defer conn.Close()
+
+	missing := NewRequest(StatusServer, "secret", nil)
+	missing.Auth = []byte("0123456789abcdef")
+	_, invalid := statusRequest()
+	invalid[len(invalid)-1] ^= 0xff
+
+	before, _ := Statistics()
+	for _, raw := range [][]byte{encode(missing, false, nil), invalid} {
+		if _, e := conn.Write(raw); e != nil {
+			t.Fatal(e)
+		}
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if n, e := conn.Read(make([]byte, MaxPacketLen)); e == nil {
+			t.Fatalf("Expected silent drop, found %d octets", n)
+		}
+	}
+	if after, _ := Statistics(); after.Invalid != before.Invalid+2 {
+		t.Fatalf("Expected 2 invalid, found=%d", after.Invalid-before.Invalid)
+	}
+}
+
+func TestStatusServerStatistics(t *testing.T) {
+	ln := testUDP(t, &Server{})
+	defer ln.Close()
+	conn := dialUDP(t, ln)
+	defer conn.Close()
+
+	statsType := VendorAttr{
+		Type:     VendorSpecific,
+		VendorId: vendor.FreeRADIUS,
+		Values: []VendorAttrString{VendorAttrString{
+			Type:  vendor.FreeRADIUSStatisticsType,
+			Value: EncodeFour(vendor.FreeRADIUSStatsAuth | vendor.FreeRADIUSStatsAcct),
+		}},
+	}.Encode()
+	auth, acct := Statistics()
+	req, raw := statusRequest(statsType)
+	res := testSend(t, conn, raw)
+	if !validStatusResponse(res, req) {
+		t.Fatal("Invalid response signature")
+	}
+
+	p, e := decode(res, len(res), "secret", false, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	found := make(map[vendor.AttributeType]uint32)
+	for _, attr := range p.Attrs {
+		b := attr.Bytes()
+		if attr.Type() != VendorSpecific || VendorSpecificHeader(b).VendorId != vendor.FreeRADIUS {
+			continue
+		}
+		for i := 4; i+6 <= len(b); i += int(b[i+1]) {
+			found[vendor.AttributeType(b[i])] = DecodeFour(b[i+2 : i+6])
+		}
+	}
+	expect := map[vendor.AttributeType]uint32{
+		vendor.FreeRADIUSTotalAccessRequests: uint32(auth.Requests),
+		vendor.FreeRADIUSTotalAuthResponses:  uint32(auth.Responses),
+		vendor.FreeRADIUSTotalAuthInvalid:    uint32(auth.Invalid),
+		vendor.FreeRADIUSTotalAccountingReq:  uint32(acct.Requests),
+		vendor.FreeRADIUSTotalAcctDuplicate:  uint32(acct.Duplicate),
+		vendor.FreeRADIUSStatsStartTime:      uint32(started.Unix()),
+	}
+	for typ, n := range expect {
+		if v, ok := found[typ]; !ok || v != n {
+			t.Fatalf("Counter %d expected %d, found=%d (present=%t)", typ, n, v, ok)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Stream transports (TLS/TCP), packets are framed by their Length header
+// https://tools.ietf.org/html/rfc6613#section-2.4
+package radius
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultIdleTimeout = 60 * time.Second
+	DefaultMaxInFlight = 16
+)
+
+func (s *Server) idleTimeout() time.Duration {
+	if s.IdleTimeout <= 0 {
+		return DefaultIdleTimeout
+	}
+	return s.IdleTimeout
+}
+
+func (s *Server) maxInFlight() int {
+	if s.MaxInFlight <= 0 {
+		return DefaultMaxInFlight
+	}
+	return s.MaxInFlight
+}
+
+// Read one packet from stream
+func readPacket(r io.Reader) ([]byte, error) {
+	hdr := make([]byte, 4)
+	if _, e := io.ReadFull(r, hdr); e != nil {
+		return nil, e
+	}
+	n := binary.BigEndian.Uint16(hdr[2:4])
+	if n < 20 || n > MaxPacketLen {
+		// No way to find the next packet, caller closes the stream
+		return nil, errors.Wrapf(ErrPacketLen, "len=%d", n)
+	}
+
+	buf := make([]byte, n)
+	copy(buf, hdr)
+	if _, e := io.ReadFull(r, buf[4:]); e != nil {
+		return nil, e
+	}
+	return buf, nil
+}
+
+// Accept connections and call serve for each of them, returns
+// when ln is closed after all connections are finished.
+func (s *Server) accept(ln net.Listener, serve func(net.Conn)) error {
+	var (
+		lock  sync.Mutex
+		conns = make(map[net.Conn]struct{})
+		wg    sync.WaitGroup
+	)
+	defer func() {
+		lock.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		lock.Unlock()
+		wg.Wait()
+	}()
+
+	for {
+		conn, e := ln.Accept()
+		if e != nil {
+			// Listener closed
+			return e
+		}
+
+		lock.Lock()
+		conns[conn] = struct{}{}
+		lock.Unlock()
+		wg.Add(1)
+		go func() {
+			defer func() {
+				conn.Close()
+				lock.Lock()
+				delete(conns, conn)
+				lock.Unlock()
+				wg.Done()
+			}()
+			serve(conn)
+		}()
+	}
+}
+
+// Handle packets from conn until it's closed or idle, at most
+// MaxInFlight packets are handled concurrently.
+func (s *Server) serveStream(conn net.Conn, ip net.IP, nas *Client) {
+	var (
+		lock sync.Mutex // One response at a time
+		wg   sync.WaitGroup
+	)
+	inFlight := make(chan struct{}, s.maxInFlight())
+	defer wg.Wait()
+
+	reply := func(b []byte) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if e := conn.SetWriteDeadline(time.Now().Add(s.timeout())); e != nil {
+			return e
+		}
+		_, e := conn.Write(b)
+		return e
+	}
+
+	first := make([]byte, 1)
+	for {
+		// Idle deadline only between packets, a timeout halfway
+		// would lose the octets read so far
+		if e := conn.SetReadDeadline(time.Now().Add(s.idleTimeout())); e != nil {
+			s.Logger.Printf("Stream deadline IP=%s e=%s", conn.RemoteAddr().String(), e.Error())
+			return
+		}
+		if _, e := io.ReadFull(conn, first); e != nil {
+			if ne, ok := e.(net.Error); ok && ne.Timeout() && len(inFlight) > 0 {
+				// Not idle, still working on requests
+				continue
+			}
+			if e != io.EOF {
+				s.Logger.Printf("Stream closed IP=%s nas=%s e=%s", conn.RemoteAddr().String(), nas.Name, e.Error())
+			}
+			return
+		}
+		if e := conn.SetReadDeadline(time.Now().Add(s.timeout())); e != nil {
+			s.Logger.Printf("Stream deadline IP=%s e=%s", conn.RemoteAddr().String(), e.Error())
+			return
+		}
+		buf, e := readPacket(io.MultiReader(bytes.NewReader(first), conn))
+		if e != nil {
+			// Incomplete packet, the stream can't be trusted anymore
+			s.Logger.Printf("Stream closed IP=%s nas=%s e=%s", conn.RemoteAddr().String(), nas.Name, e.Error())
+			return
+		}
+
+		// Stop reading when too busy, TCP pushes back to the NAS
+		inFlight <- struct{}{}
+		wg.Add(1)
+		go func(buf []byte) {
+			defer func() {
+				<-inFlight
+				wg.Done()
+			}()
+			s.process(job{
+				buf:      buf,
+				client:   conn.RemoteAddr(),
+				ip:       ip,
+				nas:      nas,
+				deadline: time.Now().Add(s.timeout()),
+				reply:    reply,
+			})
+		}(buf)
+	}
+}
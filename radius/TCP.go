@@ -0,0 +1,35 @@
+// RADIUS over TCP
+// https://tools.ietf.org/html/rfc6613
+package radius
+
+import (
+	"net"
+)
+
+func ListenTCP(addr string) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+// Accept TCP connections, returns when ln is closed
+// after all connections are finished.
+func (s *Server) ServeTCP(ln net.Listener) error {
+	whitelist, e := s.whitelist()
+	if e != nil {
+		return e
+	}
+
+	return s.accept(ln, func(conn net.Conn) {
+		addr := conn.RemoteAddr().String()
+		ip := net.ParseIP(hostOnly(addr))
+		nas := s.match(ip, whitelist)
+		if nas == nil {
+			s.Logger.Printf("TCP connection dropped for invalid IP=" + addr)
+			incr(&s.stats(0).Invalid)
+			return
+		}
+		if s.Verbose {
+			s.Logger.Printf("TCP connection IP=%s nas=%s", addr, nas.Name)
+		}
+		s.serveStream(conn, ip, nas)
+	})
+}
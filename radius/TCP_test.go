@@ -0,0 +1,126 @@
+package radius
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func testTCP(t *testing.T, idle time.Duration) net.Listener {
+	ln, e := ListenTCP("127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	testHandlers()
+	srv := &Server{
+		Secret:      "secret",
+		CIDR:        []string{"127.0.0.1/32"},
+		IdleTimeout: idle,
+		MaxInFlight: 2,
+		Logger:      testLogger(),
+	}
+	go srv.ServeTCP(ln)
+	return ln
+}
+
+func TestTCP(t *testing.T) {
+	ln := testTCP(t, time.Second)
+	defer ln.Close()
+
+	conn, e := net.Dial("tcp", ln.Addr().String())
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer conn.Close()
+
+	// Pipeline more requests than MaxInFlight in one write
+	reqs := make(map[uint8]*Packet)
+	var raw []byte
+	for i := 0; i < 5; i++ {
+		req := NewRequest(AccessRequest, "secret", []AttrEncoder{NewAttr(UserName, []byte("user"), 0)})
+		req.Auth = []byte("0123456789abcdef")
+		reqs[req.Identifier] = req
+		raw = append(raw, encode(req, false, nil)...)
+	}
+	if _, e := conn.Write(raw); e != nil {
+		t.Fatal(e)
+	}
+
+	conn.SetDeadline(time.Now().Add(time.Second))
+	for i := 0; i < 5; i++ {
+		res, e := readPacket(conn)
+		if e != nil {
+			t.Fatal(e)
+		}
+		req, ok := reqs[res[1]]
+		if !ok {
+			t.Fatalf("Response for unknown Identifier=%d", res[1])
+		}
+		if !validResponse(res, req) || PacketCode(res[0]) != AccessAccept {
+			t.Fatalf("Invalid response for Identifier=%d", res[1])
+		}
+		delete(reqs, res[1])
+	}
+}
+
+func TestTCPIdle(t *testing.T) {
+	ln := testTCP(t, 50*time.Millisecond)
+	defer ln.Close()
+
+	conn, e := net.Dial("tcp", ln.Addr().String())
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(time.Second))
+	_, e = readPacket(conn)
+	if ne, ok := e.(net.Error); e == nil || (ok && ne.Timeout()) {
+		t.Fatalf("Expected idle connection to be closed, e=%v", e)
+	}
+}
+
+func TestTCPFraming(t *testing.T) {
+	ln := testTCP(t, time.Second)
+	defer ln.Close()
+
+	conn, e := net.Dial("tcp", ln.Addr().String())
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer conn.Close()
+
+	// Length below header size, stream can't be trusted anymore
+	conn.Write([]byte{byte(AccessRequest), 1, 0, 4})
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, e := readPacket(conn); e == nil {
+		t.Fatal("Expected connection to be closed on invalid Length")
+	}
+}
+
+func TestTCPSlowFrame(t *testing.T) {
+	ln := testTCP(t, 50*time.Millisecond)
+	defer ln.Close()
+
+	conn, e := net.Dial("tcp", ln.Addr().String())
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer conn.Close()
+
+	// Pause longer than IdleTimeout halfway the packet
+	req := NewRequest(AccessRequest, "secret", []AttrEncoder{NewAttr(UserName, []byte("user"), 0)})
+	raw := encode(req, false, nil)
+	conn.Write(raw[:10])
+	time.Sleep(100 * time.Millisecond)
+	conn.Write(raw[10:])
+
+	conn.SetDeadline(time.Now().Add(time.Second))
+	res, e := readPacket(conn)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !validResponse(res, req) || PacketCode(res[0]) != AccessAccept {
+		t.Fatal("Invalid response for split packet")
+	}
+}
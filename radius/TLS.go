@@ -0,0 +1,120 @@
+// RADIUS over TLS (RadSec)
+// https://tools.ietf.org/html/rfc6614
+package radius
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+)
+
+// Shared secret is fixed for RADIUS/TLS (RFC6614 section 2.3)
+const RadSecSecret = "radsec"
+
+// Max time for the TLS handshake
+const HandshakeTimeout = 10 * time.Second
+
+// Server certificate and CA to verify NAS certificates with
+func TLSConfig(certFile string, keyFile string, caFile string) (*tls.Config, error) {
+	cert, e := tls.LoadX509KeyPair(certFile, keyFile)
+	if e != nil {
+		return nil, e
+	}
+	ca, e := ioutil.ReadFile(caFile)
+	if e != nil {
+		return nil, e
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("No certificates in CA=%s", caFile)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func ListenTLS(addr string, config *tls.Config) (net.Listener, error) {
+	return tls.Listen("tcp", addr, config)
+}
+
+// Accept RadSec connections, returns when ln is closed
+// after all connections are finished.
+func (s *Server) ServeTLS(ln net.Listener) error {
+	whitelist, e := s.whitelist()
+	if e != nil {
+		return e
+	}
+
+	return s.accept(ln, func(conn net.Conn) {
+		tlsConn, ok := conn.(*tls.Conn)
+		if !ok {
+			s.Logger.Printf("DevErr: ServeTLS needs a TLS listener")
+			return
+		}
+		s.serveTLSConn(tlsConn, whitelist)
+	})
+}
+
+func (s *Server) serveTLSConn(conn *tls.Conn, whitelist *Clients) {
+	defer conn.Close()
+	addr := conn.RemoteAddr().String()
+
+	if e := conn.SetDeadline(time.Now().Add(HandshakeTimeout)); e != nil {
+		s.Logger.Printf("TLS deadline IP=%s e=%s", addr, e.Error())
+		return
+	}
+	if e := conn.Handshake(); e != nil {
+		s.Logger.Printf("TLS handshake failed IP=%s e=%s", addr, e.Error())
+		return
+	}
+	if e := conn.SetDeadline(time.Time{}); e != nil {
+		s.Logger.Printf("TLS deadline IP=%s e=%s", addr, e.Error())
+		return
+	}
+
+	ip := net.ParseIP(hostOnly(addr))
+	nas := s.tlsClient(conn.ConnectionState(), ip, whitelist)
+	if nas == nil {
+		s.Logger.Printf("TLS connection dropped for unknown client IP=" + addr)
+		incr(&s.stats(0).Invalid)
+		return
+	}
+	if s.Verbose {
+		s.Logger.Printf("TLS connection IP=%s nas=%s", addr, nas.Name)
+	}
+
+	radsec := *nas
+	radsec.Secret = RadSecSecret
+	s.serveStream(conn, ip, &radsec)
+}
+
+// NAS for the verified client certificate, by certificate
+// name (CN or DNS SAN) or else by IP.
+func (s *Server) tlsClient(state tls.ConnectionState, ip net.IP, whitelist *Clients) *Client {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := state.PeerCertificates[0]
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	for _, name := range names {
+		if nas := s.Clients.ByName(name); nas != nil {
+			return nas
+		}
+	}
+	return s.match(ip, whitelist)
+}
+
+func hostOnly(addr string) string {
+	host, _, e := net.SplitHostPort(addr)
+	if e != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,171 @@
+package radius
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+var registerOnce sync.Once
+
+// Accept everyone, shared by the transport tests
+func testHandlers() {
+	registerOnce.Do(func() {
+		HandleFunc(AccessRequest, 0, func(w io.Writer, p *Packet) {
+			w.Write(p.Response(AccessAccept, []AttrEncoder{NewAttr(ReplyMessage, []byte(p.ClientName()), 0)}, false, nil))
+		})
+	})
+}
+
+func testLogger() *log.Logger {
+	return log.New(ioutil.Discard, "", 0)
+}
+
+// Sign a certificate with parent (self-signed if nil)
+func testCert(t *testing.T, cn string, ca bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, tls.Certificate) {
+	key, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+	serial, e := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if e != nil {
+		t.Fatal(e)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		parent, parentKey = tpl, key
+	}
+	der, e := x509.CreateCertificate(rand.Reader, tpl, parent, &key.PublicKey, parentKey)
+	if e != nil {
+		t.Fatal(e)
+	}
+	cert, e := x509.ParseCertificate(der)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return cert, key, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// Start RadSec listener with a fresh CA, returns client TLS config
+func testRadSec(t *testing.T, clientCN string) (net.Listener, *tls.Config) {
+	ca, caKey, _ := testCert(t, "Test CA", true, nil, nil)
+	_, _, server := testCert(t, "radiusd", false, ca, caKey)
+	_, _, client := testCert(t, clientCN, false, ca, caKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	ln, e := ListenTLS("127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{server},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	nas, e := NewClient("nas1", "10.0.0.0/8", "unused", "mikrotik")
+	if e != nil {
+		t.Fatal(e)
+	}
+	testHandlers()
+	srv := &Server{Clients: NewClients([]*Client{nas}), Logger: testLogger()}
+	go srv.ServeTLS(ln)
+
+	return ln, &tls.Config{
+		Certificates: []tls.Certificate{client},
+		RootCAs:      pool,
+	}
+}
+
+func TestRadSec(t *testing.T) {
+	ln, conf := testRadSec(t, "nas1")
+	defer ln.Close()
+
+	conn, e := tls.Dial("tcp", ln.Addr().String(), conf)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer conn.Close()
+
+	// Two packets to check the framing
+	for i := 0; i < 2; i++ {
+		req := NewRequest(AccessRequest, RadSecSecret, []AttrEncoder{NewAttr(UserName, []byte("user"), 0)})
+		req.Auth = []byte("0123456789abcdef")
+		if _, e := conn.Write(encode(req, false, nil)); e != nil {
+			t.Fatal(e)
+		}
+
+		raw, e := readPacket(conn)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if !validResponse(raw, req) {
+			t.Fatalf("Response not signed with %s", RadSecSecret)
+		}
+		res, e := decode(raw, len(raw), RadSecSecret, false, nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if res.Code != AccessAccept || res.Identifier != req.Identifier {
+			t.Fatalf("Unexpected response code=%s ident=%d", res.Code, res.Identifier)
+		}
+		if string(res.Attr(ReplyMessage)) != "nas1" {
+			t.Fatalf("Certificate not mapped to client, found=%s", res.Attr(ReplyMessage))
+		}
+	}
+}
+
+func TestRadSecUnknownClient(t *testing.T) {
+	ln, conf := testRadSec(t, "unknown")
+	defer ln.Close()
+
+	conn, e := tls.Dial("tcp", ln.Addr().String(), conf)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer conn.Close()
+
+	req := NewRequest(AccessRequest, RadSecSecret, nil)
+	conn.Write(encode(req, false, nil))
+	conn.SetDeadline(time.Now().Add(time.Second))
+	if _, e := readPacket(conn); e == nil {
+		t.Fatal("Expected connection to be closed for unknown client")
+	}
+}
+
+func TestRadSecNoCertificate(t *testing.T) {
+	ln, conf := testRadSec(t, "nas1")
+	defer ln.Close()
+
+	conf.Certificates = nil
+	conn, e := tls.Dial("tcp", ln.Addr().String(), conf)
+	if e == nil {
+		conn.SetDeadline(time.Now().Add(time.Second))
+		_, e = readPacket(conn)
+		conn.Close()
+	}
+	if e == nil {
+		t.Fatal("Expected handshake to fail without client certificate")
+	}
+}
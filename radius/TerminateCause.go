@@ -0,0 +1,24 @@
+package radius
+
+// Acct-Terminate-Cause values
+// https://tools.ietf.org/html/rfc2866#section-5.10
+const (
+	TerminateUserRequest    uint32 = 1
+	TerminateLostCarrier    uint32 = 2
+	TerminateLostService    uint32 = 3
+	TerminateIdleTimeout    uint32 = 4
+	TerminateSessionTimeout uint32 = 5
+	TerminateAdminReset     uint32 = 6
+	TerminateAdminReboot    uint32 = 7
+	TerminatePortError      uint32 = 8
+	TerminateNASError       uint32 = 9
+	TerminateNASRequest     uint32 = 10
+	TerminateNASReboot      uint32 = 11
+	TerminatePortUnneeded   uint32 = 12
+	TerminatePortPreempted  uint32 = 13
+	TerminatePortSuspended  uint32 = 14
+	TerminateServiceUnavail uint32 = 15
+	TerminateCallback       uint32 = 16
+	TerminateUserError      uint32 = 17
+	TerminateHostRequest    uint32 = 18
+)
@@ -17,29 +17,72 @@ func DecodeFour(b []byte) uint32 {
 	return binary.BigEndian.Uint32(b)
 }
 
+// 64-bit counter from octets and its Gigawords attribute (i.e.
+// Acct-Input-Octets + Acct-Input-Gigawords), absent attributes are 0
+func DecodeCounter(p *Packet, octets AttributeType, gigawords AttributeType) uint64 {
+	var n uint64
+	if p.HasAttr(octets) && len(p.Attr(octets)) == 4 {
+		n = uint64(DecodeFour(p.Attr(octets)))
+	}
+	if p.HasAttr(gigawords) && len(p.Attr(gigawords)) == 4 {
+		n |= uint64(DecodeFour(p.Attr(gigawords))) << 32
+	}
+	return n
+}
+
 func EncodeFour(in uint32) []byte {
 	b := make([]byte, 4)
 	binary.BigEndian.PutUint32(b, uint32(in))
 	return b
 }
 
+// Reveal User-Password (RFC2865 section 5.2)
 func DecryptPassword(raw []byte, p *Packet) string {
-	if len(raw) != 16 {
-		panic("User-Password invalid length (not 16 octets)")
+	if len(raw) < 16 || len(raw) > 128 || len(raw)%16 != 0 {
+		panic("User-Password invalid length (not multiple of 16 octets)")
+	}
+
+	out := make([]byte, len(raw))
+	last := p.Auth
+	for i := 0; i < len(raw); i += 16 {
+		h := md5.New()
+		h.Write([]byte(p.secret))
+		h.Write(last)
+		digest := h.Sum(nil)
+
+		for j := 0; j < 16; j++ {
+			// XOR
+			out[i+j] = raw[i+j] ^ digest[j]
+		}
+		last = raw[i : i+16]
 	}
 
-	h := md5.New()
-	h.Write([]byte(p.secret))
-	h.Write(p.Auth)
-	digest := h.Sum(nil)
+	out = bytes.TrimRight(out, string([]rune{0}))
+	return string(out)
+}
 
-	for i := 0; i < len(raw); i++ {
-		// XOR
-		raw[i] = raw[i] ^ digest[i]
+// Hide pass for User-Password (RFC2865 section 5.2)
+func EncryptPassword(pass string, p *Packet) []byte {
+	n := (len(pass) + 15) / 16 * 16
+	if n == 0 {
+		n = 16
 	}
+	out := make([]byte, n)
+	copy(out, pass)
 
-	raw = bytes.TrimRight(raw, string([]rune{0}))
-	return string(raw)
+	last := p.Auth
+	for i := 0; i < n; i += 16 {
+		h := md5.New()
+		h.Write([]byte(p.secret))
+		h.Write(last)
+		digest := h.Sum(nil)
+
+		for j := 0; j < 16; j++ {
+			out[i+j] ^= digest[j]
+		}
+		last = out[i : i+16]
+	}
+	return out
 }
 
 // Create a simple response.
@@ -74,8 +117,8 @@ func ValidateAuthRequest(p *Packet) string {
 	// User-Password and a CHAP-Password.
 	if !p.HasAttr(UserPassword) {
 		if !p.HasAttr(CHAPPassword) {
-			if !p.HasAttr(VendorSpecific) {
-				return "UserPassword/CHAP-Password/VendorSpeficic missing"
+			if !p.HasAttr(VendorSpecific) && !p.HasAttr(EAPMessage) && !p.HasAttr(State) {
+				return "UserPassword/CHAP-Password/VendorSpeficic/EAP-Message/State missing"
 			}
 		}
 	}
@@ -97,6 +140,30 @@ func ValidateAuthRequest(p *Packet) string {
 
 // Return non-empty string on error
 func ValidateAcctRequest(p *Packet) string {
+	if e := ValidateAcctOnOff(p); e != "" {
+		return e
+	}
+	if !p.HasAttr(NASIdentifier) {
+		return "NASIdentifier missing"
+	}
+
+	// It SHOULD contain a NAS-Port or NAS-
+	// Port-Type attribute or both unless the service does not involve a
+	// port or the NAS does not distinguish among its ports.
+	if !p.HasAttr(NASPort) {
+		return "NASPort missing"
+	}
+	if !p.HasAttr(NASPortType) {
+		return "NASPortType missing"
+	}
+
+	// All OK!
+	return ""
+}
+
+// Accounting-On/Off are about the NAS, they carry no session or port.
+// Return non-empty string on error
+func ValidateAcctOnOff(p *Packet) string {
 	// the following attributes MUST NOT be present in an Accounting-
 	// Request:  User-Password, CHAP-Password, Reply-Message, State.
 	if p.HasAttr(UserPassword) {
@@ -113,24 +180,9 @@ func ValidateAcctRequest(p *Packet) string {
 	}
 
 	// Either NAS-IP-Address or NAS-Identifier MUST be present in a
-	// RADIUS Accounting-Request.
+	// RADIUS Accounting-Request. Sessions are stored by NAS-IP-Address.
 	if !p.HasAttr(NASIPAddress) {
 		return "NASIPAddress missing"
 	}
-	if !p.HasAttr(NASIdentifier) {
-		return "NASIdentifier missing"
-	}
-
-	// It SHOULD contain a NAS-Port or NAS-
-	// Port-Type attribute or both unless the service does not involve a
-	// port or the NAS does not distinguish among its ports.
-	if !p.HasAttr(NASPort) {
-		return "NASPort missing"
-	}
-	if !p.HasAttr(NASPortType) {
-		return "NASPortType missing"
-	}
-
-	// All OK!
 	return ""
 }
@@ -0,0 +1,30 @@
+package radius
+
+import (
+	"testing"
+)
+
+func TestDecodeCounter(t *testing.T) {
+	tests := []struct {
+		attrs  []AttrEncoder
+		expect uint64
+	}{
+		// No Gigawords
+		{[]AttrEncoder{NewAttr(AcctInputOctets, EncodeFour(1000), 0)}, 1000},
+		// Only Gigawords
+		{[]AttrEncoder{NewAttr(AcctInputGigawords, EncodeFour(2), 0)}, 2 << 32},
+		// Both set
+		{[]AttrEncoder{
+			NewAttr(AcctInputOctets, EncodeFour(1000), 0),
+			NewAttr(AcctInputGigawords, EncodeFour(2), 0),
+		}, 2<<32 + 1000},
+		// Neither set
+		{nil, 0},
+	}
+	for i, test := range tests {
+		p := &Packet{Code: AccountingRequest, Attrs: test.attrs}
+		if n := DecodeCounter(p, AcctInputOctets, AcctInputGigawords); n != test.expect {
+			t.Fatalf("Test %d: expected %d, found=%d", i, test.expect, n)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package eap
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrRevoked = errors.New("eap-tls: certificate revoked")
+
+// Certificate revocation lists on disk, reloaded when changed
+type crls struct {
+	files []string
+	cas   []*x509.Certificate // To verify the CRL signatures
+
+	lock  sync.Mutex
+	mtime map[string]time.Time
+	lists []*x509.RevocationList
+}
+
+func newCRLs(files []string, cas []*x509.Certificate) (*crls, error) {
+	c := &crls{files: files, cas: cas}
+	if e := c.reload(); e != nil {
+		return nil, e
+	}
+	return c, nil
+}
+
+// Read files again if any of them changed, caller holds lock
+func (c *crls) reload() error {
+	mtime := make(map[string]time.Time)
+	changed := c.mtime == nil
+	for _, file := range c.files {
+		stat, e := os.Stat(file)
+		if e != nil {
+			return e
+		}
+		mtime[file] = stat.ModTime()
+		if !stat.ModTime().Equal(c.mtime[file]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	var lists []*x509.RevocationList
+	for _, file := range c.files {
+		raw, e := ioutil.ReadFile(file)
+		if e != nil {
+			return e
+		}
+		list, e := c.parse(raw)
+		if e != nil {
+			return errors.Wrapf(e, "crl=%s", file)
+		}
+		lists = append(lists, list...)
+	}
+	c.mtime = mtime
+	c.lists = lists
+	return nil
+}
+
+// PEM or DER, signature checked against our CAs
+func (c *crls) parse(raw []byte) ([]*x509.RevocationList, error) {
+	var ders [][]byte
+	for {
+		block, rest := pem.Decode(raw)
+		if block == nil {
+			break
+		}
+		if block.Type == "X509 CRL" {
+			ders = append(ders, block.Bytes)
+		}
+		raw = rest
+	}
+	if len(ders) == 0 {
+		ders = append(ders, raw)
+	}
+
+	var lists []*x509.RevocationList
+	for _, der := range ders {
+		list, e := x509.ParseRevocationList(der)
+		if e != nil {
+			return nil, e
+		}
+		signed := false
+		for _, ca := range c.cas {
+			if bytes.Equal(ca.RawSubject, list.RawIssuer) && list.CheckSignatureFrom(ca) == nil {
+				signed = true
+				break
+			}
+		}
+		if !signed {
+			return nil, errors.New("eap-tls: CRL not signed by CA")
+		}
+		lists = append(lists, list)
+	}
+	return lists, nil
+}
+
+// tls.Config.VerifyPeerCertificate, check every issued cert in the chains
+func (c *crls) verify(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e := c.reload(); e != nil {
+		return e
+	}
+
+	now := time.Now()
+	for _, chain := range chains {
+		for _, cert := range chain[:len(chain)-1] {
+			for _, list := range c.lists {
+				if !bytes.Equal(list.RawIssuer, cert.RawIssuer) {
+					continue
+				}
+				if !list.NextUpdate.IsZero() && now.After(list.NextUpdate) {
+					return errors.Errorf("eap-tls: CRL of %s expired", list.Issuer.String())
+				}
+				for _, revoked := range list.RevokedCertificateEntries {
+					if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+						return errors.Wrapf(ErrRevoked, "subject=%s serial=%s", cert.Subject.String(), cert.SerialNumber.String())
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
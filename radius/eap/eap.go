@@ -0,0 +1,105 @@
+// EAP packets
+// https://tools.ietf.org/html/rfc3748
+package eap
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+type Code uint8
+
+const (
+	Request  Code = 1
+	Response Code = 2
+	Success  Code = 3
+	Failure  Code = 4
+)
+
+type Type uint8
+
+const (
+	Identity     Type = 1
+	Notification Type = 2
+	Nak          Type = 3
+	MD5          Type = 4
+	TLS          Type = 13
+	TTLS         Type = 21
+	PEAP         Type = 25
+	MSCHAPv2     Type = 26
+	Extensions   Type = 33 // Inside PEAP
+)
+
+var typeNames = map[Type]string{
+	Identity:     "identity",
+	Notification: "notification",
+	Nak:          "nak",
+	MD5:          "md5",
+	TLS:          "tls",
+	TTLS:         "ttls",
+	PEAP:         "peap",
+	MSCHAPv2:     "mschapv2",
+	Extensions:   "extensions",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("Type(%d)", t)
+}
+
+// Method by name as used in config.toml (i.e. mschapv2)
+func TypeByName(name string) (Type, bool) {
+	for t, n := range typeNames {
+		if n == name && t > Nak && t != Extensions {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+var ErrPacketLen = errors.New("eap: Length invalid")
+
+type Packet struct {
+	Code       Code
+	Identifier uint8
+	Type       Type   // Request/Response only
+	Data       []byte // Type-Data
+}
+
+// Decode bytes into packet
+func Decode(b []byte) (*Packet, error) {
+	if len(b) < 4 {
+		return nil, errors.Wrapf(ErrPacketLen, "received=%d", len(b))
+	}
+	n := int(binary.BigEndian.Uint16(b[2:4]))
+	if n < 4 || n > len(b) {
+		return nil, errors.Wrapf(ErrPacketLen, "len=%d received=%d", n, len(b))
+	}
+	p := &Packet{Code: Code(b[0]), Identifier: b[1]}
+	if p.Code == Request || p.Code == Response {
+		if n < 5 {
+			return nil, errors.Wrapf(ErrPacketLen, "len=%d without Type", n)
+		}
+		p.Type = Type(b[4])
+		p.Data = b[5:n]
+	}
+	return p, nil
+}
+
+// Encode packet into bytes
+func (p *Packet) Encode() []byte {
+	if p.Code == Success || p.Code == Failure {
+		return []byte{byte(p.Code), p.Identifier, 0, 4}
+	}
+	b := make([]byte, 5+len(p.Data))
+	b[0] = byte(p.Code)
+	b[1] = p.Identifier
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	b[4] = byte(p.Type)
+	copy(b[5:], p.Data)
+	return b
+}
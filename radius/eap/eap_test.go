@@ -0,0 +1,173 @@
+package eap
+
+import (
+	"bytes"
+	"crypto/md5"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/radius/mschap"
+)
+
+func testServer(methods ...Type) *Server {
+	return NewServer(methods, "radiusd", time.Minute, func(user string) (string, error) {
+		if user == "user" {
+			return "pass", nil
+		}
+		return "", nil
+	}, false, log.New(ioutil.Discard, "", 0))
+}
+
+// Access-Request with msg and optional State
+func testRequest(msg *Packet, state []byte) *radius.Packet {
+	attrs := append(Attrs(msg.Encode()), radius.NewAttr(radius.MessageAuthenticator, make([]byte, 16), 0))
+	if state != nil {
+		attrs = append(attrs, radius.NewAttr(radius.State, state, 0))
+	}
+	req := radius.NewRequest(radius.AccessRequest, "secret", attrs)
+	req.Auth = []byte("0123456789abcdef")
+	return req
+}
+
+// Peer side of one round, returns EAP-Request and State from the reply
+func testRound(t *testing.T, s *Server, msg *Packet, state []byte) (Result, *Packet, []byte) {
+	res := s.Handle(testRequest(msg, state))
+	var raw []byte
+	for _, attr := range res.Attrs {
+		switch attr.Type() {
+		case radius.EAPMessage:
+			raw = append(raw, attr.Bytes()...)
+		case radius.State:
+			state = attr.Bytes()
+		}
+	}
+	p, e := Decode(raw)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return res, p, state
+}
+
+func identity(user string) *Packet {
+	return &Packet{Code: Response, Identifier: 0, Type: Identity, Data: []byte(user)}
+}
+
+func testMD5(t *testing.T, user, pass string) Result {
+	s := testServer(MD5)
+	_, req, state := testRound(t, s, identity(user), nil)
+	if req.Code != Request || req.Type != MD5 {
+		t.Fatalf("Expected MD5 request, found code=%d type=%s", req.Code, req.Type)
+	}
+
+	h := md5.New()
+	h.Write([]byte{req.Identifier})
+	h.Write([]byte(pass))
+	h.Write(req.Data[1:17])
+	data := append([]byte{16}, h.Sum(nil)...)
+
+	res, _, _ := testRound(t, s, &Packet{Code: Response, Identifier: req.Identifier, Type: MD5, Data: data}, state)
+	if s.Pending() != 0 {
+		t.Fatal("Conversation not removed")
+	}
+	return res
+}
+
+func TestMD5(t *testing.T) {
+	if res := testMD5(t, "user", "pass"); res.Code != radius.AccessAccept || res.Identity != "user" {
+		t.Fatalf("Expected accept, found=%s", res.Code)
+	}
+	if res := testMD5(t, "user", "wrong"); res.Code != radius.AccessReject {
+		t.Fatalf("Expected reject, found=%s", res.Code)
+	}
+	if res := testMD5(t, "unknown", "pass"); res.Code != radius.AccessReject {
+		t.Fatalf("Expected reject, found=%s", res.Code)
+	}
+}
+
+func TestMSCHAPv2(t *testing.T) {
+	// Propose MD5 first to check Nak
+	s := testServer(MD5, MSCHAPv2)
+	_, req, state := testRound(t, s, identity("user"), nil)
+	if req.Type != MD5 {
+		t.Fatalf("Expected MD5 request, found=%s", req.Type)
+	}
+	_, req, state = testRound(t, s, &Packet{Code: Response, Identifier: req.Identifier, Type: Nak, Data: []byte{byte(MSCHAPv2)}}, state)
+	if req.Type != MSCHAPv2 || req.Data[0] != mschapChallenge {
+		t.Fatalf("Expected MSCHAPv2 challenge, found=%s", req.Type)
+	}
+
+	challenge := req.Data[5:21]
+	peerChallenge := []byte("fedcba9876543210")
+	enc, e := mschap.Encryptv2(challenge, peerChallenge, "user", "pass")
+	if e != nil {
+		t.Fatal(e)
+	}
+	value := append([]byte{49}, peerChallenge...)
+	value = append(value, make([]byte, 8)...)
+	value = append(value, enc.ChallengeResponse...)
+	value = append(value, 0)
+	value = append(value, []byte("user")...)
+	data := mschapPacket(mschapResponse, req.Data[1], value)
+
+	_, req, state = testRound(t, s, &Packet{Code: Response, Identifier: req.Identifier, Type: MSCHAPv2, Data: data}, state)
+	if req.Code != Request || req.Data[0] != mschapSuccess {
+		t.Fatalf("Expected Success-Request, found code=%d", req.Code)
+	}
+	if !bytes.Equal(req.Data[4:], []byte(enc.AuthenticatorResponse)) {
+		t.Fatalf("Authenticator response mismatch, found=%s", req.Data[4:])
+	}
+
+	res, success, _ := testRound(t, s, &Packet{Code: Response, Identifier: req.Identifier, Type: MSCHAPv2, Data: []byte{mschapSuccess}}, state)
+	if res.Code != radius.AccessAccept || success.Code != Success {
+		t.Fatalf("Expected accept, found=%s", res.Code)
+	}
+	keys := 0
+	for _, attr := range res.Attrs {
+		if attr.Type() == radius.VendorSpecific {
+			keys++
+		}
+	}
+	if keys != 1 {
+		t.Fatal("MPPE keys missing")
+	}
+}
+
+func TestUnknownState(t *testing.T) {
+	s := testServer(MD5)
+	res, p, _ := testRound(t, s, &Packet{Code: Response, Identifier: 1, Type: MD5, Data: []byte{16}}, []byte("unknown"))
+	if res.Code != radius.AccessReject || p.Code != Failure {
+		t.Fatalf("Expected reject, found=%s", res.Code)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	s := testServer(MD5)
+	s.conversations.timeout = 10 * time.Millisecond
+	_, _, state := testRound(t, s, identity("user"), nil)
+	time.Sleep(20 * time.Millisecond)
+
+	// Only new conversations, expired one swept on add
+	testRound(t, s, identity("user"), nil)
+	if s.Pending() != 1 {
+		t.Fatalf("Expected 1 conversation, found=%d", s.Pending())
+	}
+	res, _, _ := testRound(t, s, &Packet{Code: Response, Identifier: 1, Type: MD5, Data: []byte{16}}, state)
+	if res.Code != radius.AccessReject {
+		t.Fatalf("Expected reject, found=%s", res.Code)
+	}
+}
+
+func TestAttrs(t *testing.T) {
+	msg := bytes.Repeat([]byte{1}, 600)
+	attrs := Attrs(msg)
+	if len(attrs) != 3 || len(attrs[0].Bytes()) != maxAttrLen {
+		t.Fatalf("Expected 3 attrs, found=%d", len(attrs))
+	}
+	req := radius.NewRequest(radius.AccessRequest, "secret", attrs)
+	if !bytes.Equal(Message(req), msg) {
+		t.Fatal("Reassembled message mismatch")
+	}
+}
@@ -0,0 +1,60 @@
+// EAP-TLS, certificate based authentication
+// https://tools.ietf.org/html/rfc5216
+package eap
+
+import (
+	"github.com/mpdroog/radiusd/radius/mschap"
+)
+
+type tlsMethod struct {
+	tunnel *tlsTunnel
+}
+
+func (m *tlsMethod) Type() Type {
+	return TLS
+}
+
+func (m *tlsMethod) Start(c *Conversation) ([]byte, error) {
+	m.tunnel = newServerTunnel(c.server.ClientTLS, 0)
+	return m.tunnel.start(), nil
+}
+
+func (m *tlsMethod) Next(c *Conversation, data []byte) ([]byte, bool, error) {
+	req, done, e := m.tunnel.next(data, func(app []byte) ([]byte, bool, error) {
+		// Peer acknowledged our Finished, nothing inside the tunnel
+		return nil, true, nil
+	})
+	if !done || e != nil {
+		return req, done, e
+	}
+
+	// Chain already verified by crypto/tls
+	certs := m.tunnel.tls.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, true, ErrUnknownUser
+	}
+	user, e := c.server.CertUser(certs[0])
+	if e != nil {
+		return nil, true, e
+	}
+	if user == "" {
+		return nil, true, ErrUnknownUser
+	}
+
+	send, recv, e := m.tunnel.keys(mskLabel)
+	if e != nil {
+		return nil, true, e
+	}
+	c.SetKeys(func(secret string, reqAuth []byte) ([]byte, []byte) {
+		return mschap.MppeKeys(secret, reqAuth, send, recv)
+	})
+	c.Identity = user
+	return nil, true, nil
+}
+
+func (m *tlsMethod) Close() error {
+	if m.tunnel == nil {
+		return nil
+	}
+	return m.tunnel.Close()
+}
@@ -0,0 +1,153 @@
+package eap
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mpdroog/radiusd/radius"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T, dir string) *testCA {
+	key, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, e := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if e != nil {
+		t.Fatal(e)
+	}
+	cert, e := x509.ParseCertificate(der)
+	if e != nil {
+		t.Fatal(e)
+	}
+	file := filepath.Join(dir, "ca.pem")
+	if e := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); e != nil {
+		t.Fatal(e)
+	}
+	return &testCA{cert: cert, key: key, file: file}
+}
+
+func (ca *testCA) issue(t *testing.T, cn string, serial int64, usage x509.ExtKeyUsage) tls.Certificate {
+	key, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{cn},
+	}
+	der, e := x509.CreateCertificate(rand.Reader, tpl, ca.cert, &key.PublicKey, ca.key)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (ca *testCA) crl(t *testing.T, dir string, serials ...int64) string {
+	tpl := &x509.RevocationList{
+		Number:     big.NewInt(time.Now().UnixNano()),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	for _, serial := range serials {
+		tpl.RevokedCertificateEntries = append(tpl.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now(),
+		})
+	}
+	der, e := x509.CreateRevocationList(rand.Reader, tpl, ca.cert, ca.key)
+	if e != nil {
+		t.Fatal(e)
+	}
+	file := filepath.Join(dir, "ca.crl")
+	if e := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0600); e != nil {
+		t.Fatal(e)
+	}
+	return file
+}
+
+func testEAPTLS(t *testing.T, cn string, revoked ...int64) Result {
+	dir, e := ioutil.TempDir("", "eaptls")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCA(t, dir)
+	server := ca.issue(t, "radiusd", 2, x509.ExtKeyUsageServerAuth)
+	client := ca.issue(t, cn, 3, x509.ExtKeyUsageClientAuth)
+
+	s := testServer(TLS)
+	s.TLS = &tls.Config{Certificates: []tls.Certificate{server}, MaxVersion: tls.VersionTLS12, SessionTicketsDisabled: true}
+	s.ClientTLS, e = ClientTLSConfig(s.TLS, ca.file, []string{ca.crl(t, dir, revoked...)})
+	if e != nil {
+		t.Fatal(e)
+	}
+	s.CertUser = func(cert *x509.Certificate) (string, error) {
+		if cert.Subject.CommonName == "user" {
+			return "user", nil
+		}
+		return "", nil
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	peer := newTLSPeer(t, TLS, &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{client}}, func(app []byte) ([]byte, bool, error) {
+		return nil, false, nil
+	})
+	res, req := testTunnel(t, s, peer)
+	if res.Code == radius.AccessAccept {
+		testKeys(t, res, peer, req, mskLabel)
+	}
+	return res
+}
+
+func TestEAPTLS(t *testing.T) {
+	res := testEAPTLS(t, "user")
+	if res.Code != radius.AccessAccept || res.Identity != "user" {
+		t.Fatalf("Expected accept, found=%s identity=%s", res.Code, res.Identity)
+	}
+}
+
+func TestEAPTLSUnknownUser(t *testing.T) {
+	if res := testEAPTLS(t, "unknown"); res.Code != radius.AccessReject {
+		t.Fatalf("Expected reject, found=%s", res.Code)
+	}
+}
+
+func TestEAPTLSRevoked(t *testing.T) {
+	if res := testEAPTLS(t, "user", 3); res.Code != radius.AccessReject {
+		t.Fatalf("Expected reject, found=%s", res.Code)
+	}
+}
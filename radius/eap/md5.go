@@ -0,0 +1,51 @@
+// EAP-MD5 (CHAP inside EAP)
+// https://tools.ietf.org/html/rfc3748#section-5.4
+package eap
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+
+	"github.com/mpdroog/radiusd/passwd"
+	"github.com/pkg/errors"
+)
+
+type md5Method struct {
+	challenge []byte
+}
+
+func (m *md5Method) Type() Type {
+	return MD5
+}
+
+// Value-Size + Value + Name
+func (m *md5Method) Start(c *Conversation) ([]byte, error) {
+	m.challenge = make([]byte, 16)
+	if _, e := rand.Read(m.challenge); e != nil {
+		return nil, e
+	}
+	data := append([]byte{byte(len(m.challenge))}, m.challenge...)
+	return append(data, []byte(c.server.Name)...), nil
+}
+
+// MD5(Identifier + password + challenge)
+func (m *md5Method) Next(c *Conversation, data []byte) ([]byte, bool, error) {
+	if len(data) < 1 || int(data[0]) != md5.Size || len(data) < 1+md5.Size {
+		return nil, true, errors.Errorf("eap-md5: Value-Size invalid")
+	}
+	stored, e := c.Password(passwd.CHAP)
+	if e != nil {
+		return nil, true, e
+	}
+	pass, _ := stored.Cleartext()
+
+	h := md5.New()
+	h.Write([]byte{c.Identifier()})
+	h.Write([]byte(pass))
+	h.Write(m.challenge)
+	if subtle.ConstantTimeCompare(h.Sum(nil), data[1:1+md5.Size]) != 1 {
+		return nil, true, ErrAuth
+	}
+	return nil, true, nil
+}
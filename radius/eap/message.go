@@ -0,0 +1,44 @@
+// EAP over RADIUS
+// https://tools.ietf.org/html/rfc3579
+package eap
+
+import (
+	"github.com/mpdroog/radiusd/radius"
+)
+
+// Max value of a RADIUS attribute
+const maxAttrLen = 253
+
+// Concatenate all EAP-Message attributes (RFC3579 section 3.1)
+func Message(p *radius.Packet) []byte {
+	var msg []byte
+	for _, attr := range p.Attrs {
+		if attr.Type() == radius.EAPMessage {
+			msg = append(msg, attr.Bytes()...)
+		}
+	}
+	return msg
+}
+
+// Split msg over as many EAP-Message attributes as needed
+func Attrs(msg []byte) []radius.AttrEncoder {
+	var attrs []radius.AttrEncoder
+	for len(msg) > 0 {
+		n := len(msg)
+		if n > maxAttrLen {
+			n = maxAttrLen
+		}
+		attrs = append(attrs, radius.NewAttr(radius.EAPMessage, msg[:n], 0))
+		msg = msg[n:]
+	}
+	return attrs
+}
+
+// EAP-Failure to add to an Access-Reject for req
+func FailureAttrs(req *radius.Packet) []radius.AttrEncoder {
+	p, e := Decode(Message(req))
+	if e != nil {
+		return nil
+	}
+	return Attrs((&Packet{Code: Failure, Identifier: p.Identifier}).Encode())
+}
@@ -0,0 +1,95 @@
+// EAP-MSCHAPv2
+// https://tools.ietf.org/html/draft-kamath-pppext-eap-mschapv2-02
+package eap
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+
+	"github.com/mpdroog/radiusd/passwd"
+	"github.com/mpdroog/radiusd/radius/mschap"
+	"github.com/pkg/errors"
+)
+
+// OpCodes
+const (
+	mschapChallenge = 1
+	mschapResponse  = 2
+	mschapSuccess   = 3
+	mschapFailure   = 4
+)
+
+type mschapv2Method struct {
+	challenge []byte
+	success   bool // Success-Request sent, waiting for peer ack
+}
+
+func (m *mschapv2Method) Type() Type {
+	return MSCHAPv2
+}
+
+// OpCode + MS-CHAPv2-ID + MS-Length + value
+func mschapPacket(opCode uint8, id uint8, value []byte) []byte {
+	b := make([]byte, 4+len(value))
+	b[0] = opCode
+	b[1] = id
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	copy(b[4:], value)
+	return b
+}
+
+// Challenge: Value-Size + Challenge + Name
+func (m *mschapv2Method) Start(c *Conversation) ([]byte, error) {
+	m.challenge = make([]byte, 16)
+	if _, e := rand.Read(m.challenge); e != nil {
+		return nil, e
+	}
+	value := append([]byte{16}, m.challenge...)
+	value = append(value, []byte(c.server.Name)...)
+	return mschapPacket(mschapChallenge, c.Identifier()+1, value), nil
+}
+
+func (m *mschapv2Method) Next(c *Conversation, data []byte) ([]byte, bool, error) {
+	if len(data) < 1 {
+		return nil, true, errors.New("eap-mschapv2: OpCode missing")
+	}
+	if m.success {
+		// Peer acknowledged our Success-Request
+		if data[0] != mschapSuccess {
+			return nil, true, errors.Errorf("eap-mschapv2: expected success ack, opcode=%d", data[0])
+		}
+		return nil, true, nil
+	}
+	if data[0] != mschapResponse {
+		return nil, true, errors.Errorf("eap-mschapv2: expected response, opcode=%d", data[0])
+	}
+
+	// OpCode(1) MS-CHAPv2-ID(1) MS-Length(2) Value-Size(1) Response(49) Name
+	if len(data) < 54 || data[4] != 49 {
+		return nil, true, errors.New("eap-mschapv2: Response too short")
+	}
+	id := data[1]
+	peerChallenge := data[5:21]
+	ntResponse := append([]byte{}, data[29:53]...)
+	name := string(data[54:])
+
+	stored, e := c.Password(passwd.MSCHAP)
+	if e != nil {
+		return nil, true, e
+	}
+	hash, _ := stored.NTHash()
+	enc, e := mschap.Encryptv2NT(m.challenge, peerChallenge, name, hash)
+	if e != nil {
+		return nil, true, e
+	}
+	if subtle.ConstantTimeCompare(enc.ChallengeResponse, ntResponse) != 1 {
+		return nil, true, ErrAuth
+	}
+
+	c.SetKeys(func(secret string, reqAuth []byte) ([]byte, []byte) {
+		return mschap.Mmpev2NT(secret, hash, reqAuth, ntResponse)
+	})
+	m.success = true
+	return mschapPacket(mschapSuccess, id, []byte(enc.AuthenticatorResponse)), false, nil
+}
@@ -0,0 +1,125 @@
+// PEAPv0 with EAP-MSCHAPv2 inside the TLS tunnel
+// https://tools.ietf.org/html/draft-kamath-pppext-peapv0-00
+// https://tools.ietf.org/html/draft-josefsson-pppext-eap-tls-eap-06
+package eap
+
+import (
+	"encoding/binary"
+
+	"github.com/mpdroog/radiusd/radius/mschap"
+	"github.com/pkg/errors"
+)
+
+// Result TLV inside Extensions
+const (
+	resultTLV     = 3
+	resultSuccess = 1
+	resultFailure = 2
+)
+
+type peapMethod struct {
+	tunnel   *tlsTunnel
+	inner    *Conversation // EAP-MSCHAPv2 with the real identity
+	result   uint16        // Result TLV sent, waiting for the peer
+	innerErr error
+}
+
+func (m *peapMethod) Type() Type {
+	return PEAP
+}
+
+func (m *peapMethod) Start(c *Conversation) ([]byte, error) {
+	m.tunnel = newServerTunnel(c.server.TLS, 0)
+	return m.tunnel.start(), nil
+}
+
+func (m *peapMethod) Next(c *Conversation, data []byte) ([]byte, bool, error) {
+	req, done, e := m.tunnel.next(data, func(app []byte) ([]byte, bool, error) {
+		return m.phase2(c, app)
+	})
+	if !done || e != nil {
+		return req, done, e
+	}
+
+	send, recv, e := m.tunnel.keys(mskLabel)
+	if e != nil {
+		return nil, true, e
+	}
+	c.SetKeys(func(secret string, reqAuth []byte) ([]byte, []byte) {
+		return mschap.MppeKeys(secret, reqAuth, send, recv)
+	})
+	// Outer identity is usually anonymous
+	c.Identity = m.inner.Identity
+	return nil, true, nil
+}
+
+func (m *peapMethod) Close() error {
+	if m.tunnel == nil {
+		return nil
+	}
+	return m.tunnel.Close()
+}
+
+// Inner EAP, without Code/Identifier/Length headers except for
+// Extensions (PEAPv0 section 1.1)
+func (m *peapMethod) phase2(c *Conversation, app []byte) ([]byte, bool, error) {
+	if m.result != 0 {
+		return m.extensionsResult(app)
+	}
+	if m.inner == nil {
+		if len(app) == 0 {
+			// Tunnel established
+			return []byte{byte(Identity)}, false, nil
+		}
+		if Type(app[0]) != Identity {
+			return nil, true, errors.Errorf("peap: expected identity, found=%s", Type(app[0]))
+		}
+
+		m.inner = &Conversation{Identity: string(app[1:]), server: c.server, method: &mschapv2Method{}}
+		data, e := m.inner.method.Start(m.inner)
+		if e != nil {
+			return nil, true, e
+		}
+		return append([]byte{byte(MSCHAPv2)}, data...), false, nil
+	}
+
+	if len(app) == 0 || Type(app[0]) != MSCHAPv2 {
+		m.innerErr = errors.New("peap: expected mschapv2")
+		return m.extensions(c, resultFailure), false, nil
+	}
+	data, done, e := m.inner.method.Next(m.inner, app[1:])
+	if !done {
+		return append([]byte{byte(MSCHAPv2)}, data...), false, nil
+	}
+	if e != nil {
+		m.innerErr = e
+		return m.extensions(c, resultFailure), false, nil
+	}
+	return m.extensions(c, resultSuccess), false, nil
+}
+
+// Extensions request with a mandatory Result TLV
+func (m *peapMethod) extensions(c *Conversation, result uint16) []byte {
+	m.result = result
+	tlv := []byte{0x80, resultTLV, 0, 2, 0, 0}
+	binary.BigEndian.PutUint16(tlv[4:], result)
+	return (&Packet{Code: Request, Identifier: c.Identifier() + 1, Type: Extensions, Data: tlv}).Encode()
+}
+
+// Peer echoes our Result TLV
+func (m *peapMethod) extensionsResult(app []byte) ([]byte, bool, error) {
+	p, e := Decode(app)
+	if e != nil {
+		return nil, true, e
+	}
+	if p.Code != Response || p.Type != Extensions || len(p.Data) < 6 || p.Data[1] != resultTLV {
+		return nil, true, errors.New("peap: expected Result TLV")
+	}
+	if m.result != resultSuccess {
+		return nil, true, m.innerErr
+	}
+	if binary.BigEndian.Uint16(p.Data[4:6]) != resultSuccess {
+		return nil, true, errors.New("peap: peer result failure")
+	}
+	return nil, true, nil
+}
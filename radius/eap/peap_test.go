@@ -0,0 +1,243 @@
+package eap
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/radius/mschap"
+	"github.com/mpdroog/radiusd/radius/vendor"
+)
+
+// Self-signed server certificate, big enough to be fragmented
+func testCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, e := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if e != nil {
+		t.Fatal(e)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "radiusd"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"radiusd"},
+	}
+	for i := 0; i < 60; i++ {
+		tpl.DNSNames = append(tpl.DNSNames, fmt.Sprintf("radius-%d.example.com", i))
+	}
+	der, e := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if e != nil {
+		t.Fatal(e)
+	}
+	cert, e := x509.ParseCertificate(der)
+	if e != nil {
+		t.Fatal(e)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// Supplicant side of a TLS tunnel method
+type tlsPeer struct {
+	t         *testing.T
+	typ       Type
+	tunnel    *tlsTunnel
+	phase2    phase2Func
+	fragments int // Fragments received
+}
+
+func newTLSPeer(t *testing.T, typ Type, conf *tls.Config, phase2 phase2Func) *tlsPeer {
+	conn := newTLSConn()
+	conf.ServerName = "radiusd"
+	conf.MaxVersion = tls.VersionTLS12
+	return &tlsPeer{t: t, typ: typ, tunnel: &tlsTunnel{conn: conn, tls: tls.Client(conn, conf)}, phase2: phase2}
+}
+
+func (p *tlsPeer) respond(req *Packet) *Packet {
+	if len(req.Data) > 0 && req.Data[0]&flagMore != 0 {
+		p.fragments++
+	}
+	data, done, e := p.tunnel.next(req.Data, p.phase2)
+	if done || e != nil {
+		p.t.Fatalf("Peer stopped e=%v", e)
+	}
+	return &Packet{Code: Response, Identifier: req.Identifier, Type: p.typ, Data: data}
+}
+
+// Run the conversation until Access-Accept/Reject, returns
+// the last Access-Request as well
+func testTunnel(t *testing.T, s *Server, peer *tlsPeer) (Result, *radius.Packet) {
+	_, req, state := testRound(t, s, identity("anonymous"), nil)
+	for i := 0; i < 30; i++ {
+		if req.Code != Request || req.Type != peer.typ {
+			t.Fatalf("Expected %s request, found code=%d type=%s", peer.typ, req.Code, req.Type)
+		}
+		rreq := testRequest(peer.respond(req), state)
+		res := s.Handle(rreq)
+		if res.Code != radius.AccessChallenge {
+			if s.Pending() != 0 {
+				t.Fatal("Conversation not removed")
+			}
+			return res, rreq
+		}
+		var raw []byte
+		for _, attr := range res.Attrs {
+			if attr.Type() == radius.EAPMessage {
+				raw = append(raw, attr.Bytes()...)
+			}
+		}
+		var e error
+		if req, e = Decode(raw); e != nil {
+			t.Fatal(e)
+		}
+	}
+	t.Fatal("Too many rounds")
+	return Result{}, nil
+}
+
+// Inner MSCHAPv2 of PEAPv0
+type peapPeer struct {
+	t         *testing.T
+	user      string
+	pass      string
+	challenge []byte
+	expect    string // Authenticator response
+}
+
+func (p *peapPeer) phase2(app []byte) ([]byte, bool, error) {
+	if len(app) == 0 {
+		// Handshake finished, ack
+		return nil, false, nil
+	}
+	if req, e := Decode(app); e == nil && req.Code == Request && req.Type == Extensions && len(app) == 5+len(req.Data) {
+		// Full header, echo Result TLV
+		return (&Packet{Code: Response, Identifier: req.Identifier, Type: Extensions, Data: req.Data}).Encode(), false, nil
+	}
+
+	switch Type(app[0]) {
+	case Identity:
+		return append([]byte{byte(Identity)}, []byte(p.user)...), false, nil
+	case MSCHAPv2:
+		data := app[1:]
+		switch data[0] {
+		case mschapChallenge:
+			p.challenge = data[5:21]
+			peerChallenge := []byte("fedcba9876543210")
+			enc, e := mschap.Encryptv2(p.challenge, peerChallenge, p.user, p.pass)
+			if e != nil {
+				return nil, true, e
+			}
+			p.expect = enc.AuthenticatorResponse
+			value := append([]byte{49}, peerChallenge...)
+			value = append(value, make([]byte, 8)...)
+			value = append(value, enc.ChallengeResponse...)
+			value = append(value, 0)
+			value = append(value, []byte(p.user)...)
+			return append([]byte{byte(MSCHAPv2)}, mschapPacket(mschapResponse, data[1], value)...), false, nil
+		case mschapSuccess:
+			if string(data[4:]) != p.expect {
+				p.t.Fatalf("Authenticator response mismatch")
+			}
+			return []byte{byte(MSCHAPv2), mschapSuccess}, false, nil
+		}
+	}
+	p.t.Fatalf("Peer unexpected inner=%x", app)
+	return nil, true, nil
+}
+
+// Reverse mschap.MppeKeys for one key
+func decryptKey(secret string, reqAuth []byte, enc []byte) []byte {
+	salt, c := enc[:2], enc[2:]
+	var plain []byte
+	prev := append(append([]byte{}, reqAuth...), salt...)
+	for i := 0; i < len(c); i += 16 {
+		h := md5.New()
+		h.Write([]byte(secret))
+		h.Write(prev)
+		b := h.Sum(nil)
+		for j := 0; j < 16; j++ {
+			plain = append(plain, c[i+j]^b[j])
+		}
+		prev = c[i : i+16]
+	}
+	return plain[1 : 1+plain[0]]
+}
+
+func testPEAP(t *testing.T, pass string) (Result, *tlsPeer, *radius.Packet) {
+	cert, pool := testCert(t)
+	s := testServer(PEAP)
+	s.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MaxVersion: tls.VersionTLS12, SessionTicketsDisabled: true}
+
+	inner := &peapPeer{t: t, user: "user", pass: pass}
+	peer := newTLSPeer(t, PEAP, &tls.Config{RootCAs: pool}, inner.phase2)
+	res, req := testTunnel(t, s, peer)
+	return res, peer, req
+}
+
+// MS-MPPE-Send-Key and MS-MPPE-Recv-Key must match the peer's
+func testKeys(t *testing.T, res Result, peer *tlsPeer, req *radius.Packet, label string) {
+	send, recv, e := peer.tunnel.keys(label)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var found int
+	for _, attr := range res.Attrs {
+		if attr.Type() != radius.VendorSpecific {
+			continue
+		}
+		b := attr.Bytes()[4:]
+		for len(b) > 2 {
+			value := b[2:b[1]]
+			switch vendor.AttributeType(b[0]) {
+			case vendor.MSMPPESendKey:
+				if !bytes.Equal(decryptKey("secret", req.Auth, value), send) {
+					t.Fatal("MS-MPPE-Send-Key mismatch")
+				}
+				found++
+			case vendor.MSMPPERecvKey:
+				if !bytes.Equal(decryptKey("secret", req.Auth, value), recv) {
+					t.Fatal("MS-MPPE-Recv-Key mismatch")
+				}
+				found++
+			}
+			b = b[b[1]:]
+		}
+	}
+	if found != 2 {
+		t.Fatalf("Expected 2 MPPE keys, found=%d", found)
+	}
+}
+
+func TestPEAP(t *testing.T) {
+	res, peer, req := testPEAP(t, "pass")
+	if res.Code != radius.AccessAccept || res.Identity != "user" {
+		t.Fatalf("Expected accept for inner identity, found=%s identity=%s", res.Code, res.Identity)
+	}
+	if peer.fragments == 0 {
+		t.Fatal("Expected server certificate to be fragmented")
+	}
+
+	testKeys(t, res, peer, req, mskLabel)
+}
+
+func TestPEAPInvalidPassword(t *testing.T) {
+	res, _, _ := testPEAP(t, "wrong")
+	if res.Code != radius.AccessReject {
+		t.Fatalf("Expected reject, found=%s", res.Code)
+	}
+}
@@ -0,0 +1,253 @@
+package eap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"time"
+
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/radius/vendor"
+	"github.com/pkg/errors"
+)
+
+// Authentication method after EAP-Identity
+type Method interface {
+	Type() Type
+	// Type-Data of the first request
+	Start(c *Conversation) ([]byte, error)
+	// Handle Type-Data of a response, returns Type-Data of the next
+	// request or done when finished (e is nil on success)
+	Next(c *Conversation, data []byte) (req []byte, done bool, e error)
+}
+
+// Encrypt MPPE keys with the secret and Request Authenticator
+// of the Access-Request we answer with Access-Accept
+type KeyFunc func(secret string, reqAuth []byte) (send []byte, recv []byte)
+
+// Stored password for user (see passwd.Parse), "" if unknown
+type PasswordFunc func(user string) (string, error)
+
+// User for a verified client certificate, "" if unknown
+type CertUserFunc func(cert *x509.Certificate) (string, error)
+
+// Verify PAP/CHAP/MS-CHAP of an EAP-TTLS inner request, returns
+// the reply attributes (i.e. MS-CHAP2-Success) or the reason to reject
+type InnerFunc func(req *radius.Packet) ([]radius.AttrEncoder, error)
+
+var (
+	ErrUnknownUser = errors.New("eap: unknown user")
+	ErrAuth        = errors.New("eap: invalid credentials")
+	ErrNak         = errors.New("eap: no acceptable method")
+	ErrFormat      = errors.New("eap: stored password unusable for method")
+)
+
+// Outcome of one Access-Request
+type Result struct {
+	Code     radius.PacketCode // AccessChallenge, AccessAccept, AccessReject or 0 to drop
+	Identity string            // Authenticated user on AccessAccept
+	Attrs    []radius.AttrEncoder
+}
+
+type Server struct {
+	Methods  []Type // Preference, first is proposed after EAP-Identity
+	Name     string // Authenticator name sent to the peer
+	Password PasswordFunc
+	Verbose  bool
+	Logger   *log.Logger
+
+	TLS       *tls.Config  // Server certificate for PEAP, nil to disable
+	ClientTLS *tls.Config  // EAP-TLS client verification, nil to disable
+	CertUser  CertUserFunc // EAP-TLS certificate to user
+	Inner     InnerFunc    // EAP-TTLS inner authentication
+
+	conversations *conversations
+}
+
+func NewServer(methods []Type, name string, timeout time.Duration, password PasswordFunc, verbose bool, logger *log.Logger) *Server {
+	return &Server{
+		Methods:       methods,
+		Name:          name,
+		Password:      password,
+		Verbose:       verbose,
+		Logger:        logger,
+		conversations: newConversations(timeout),
+	}
+}
+
+// Conversations in progress
+func (s *Server) Pending() int {
+	return s.conversations.len()
+}
+
+// Method implementation for t, nil if unsupported
+func (s *Server) method(t Type) Method {
+	for _, m := range s.Methods {
+		if m != t {
+			continue
+		}
+		switch t {
+		case MD5:
+			return &md5Method{}
+		case MSCHAPv2:
+			return &mschapv2Method{}
+		case PEAP:
+			if s.TLS != nil {
+				return &peapMethod{}
+			}
+		case TTLS:
+			if s.TLS != nil && s.Inner != nil {
+				return &ttlsMethod{}
+			}
+		case TLS:
+			if s.ClientTLS != nil && s.CertUser != nil {
+				return &tlsMethod{}
+			}
+		}
+	}
+	return nil
+}
+
+// Process the EAP-Message of an Access-Request
+func (s *Server) Handle(req *radius.Packet) Result {
+	// Without Message-Authenticator MUST be silently discarded (RFC3579 section 3.2)
+	if !req.HasAttr(radius.MessageAuthenticator) {
+		s.Logger.Printf("eap: dropped for missing Message-Authenticator")
+		return Result{}
+	}
+	p, e := Decode(Message(req))
+	if e != nil {
+		s.Logger.Printf("eap: dropped e=%s", e.Error())
+		return Result{}
+	}
+	if p.Code != Response {
+		s.Logger.Printf("eap: dropped for code=%d", p.Code)
+		return Result{}
+	}
+
+	var conv *Conversation
+	if req.HasAttr(radius.State) {
+		conv = s.conversations.get(req.Attr(radius.State))
+		if conv == nil {
+			return s.failure(nil, p, errors.New("eap: unknown or expired State"))
+		}
+	} else if p.Type == Identity {
+		conv = &Conversation{Identity: string(p.Data), id: p.Identifier, server: s}
+		if e := s.conversations.add(conv); e != nil {
+			s.Logger.Printf("eap: %s", e.Error())
+			return Result{}
+		}
+		conv.lock.Lock()
+		defer conv.lock.Unlock()
+		if len(s.Methods) == 0 {
+			return s.failure(conv, p, ErrNak)
+		}
+		return s.start(conv, p, s.method(s.Methods[0]))
+	} else {
+		return s.failure(nil, p, errors.New("eap: expected Identity"))
+	}
+
+	conv.lock.Lock()
+	defer conv.lock.Unlock()
+	if conv.closed {
+		// Expired while waiting for the lock
+		return s.failure(nil, p, errors.New("eap: unknown or expired State"))
+	}
+	if p.Identifier != conv.id {
+		// Silently discard (RFC3748 section 4.1)
+		if s.Verbose {
+			s.Logger.Printf("eap: dropped identifier=%d expected=%d", p.Identifier, conv.id)
+		}
+		return Result{}
+	}
+
+	if p.Type == Nak {
+		// Peer wants another method, take the first we support
+		for _, t := range p.Data {
+			if m := s.method(Type(t)); m != nil && Type(t) != conv.method.Type() {
+				return s.start(conv, p, m)
+			}
+		}
+		return s.failure(conv, p, ErrNak)
+	}
+	if p.Type != conv.method.Type() {
+		return s.failure(conv, p, errors.Errorf("eap: expected type=%s received=%s", conv.method.Type(), p.Type))
+	}
+
+	data, done, e := conv.method.Next(conv, p.Data)
+	if !done {
+		return s.challenge(conv, data)
+	}
+	if e != nil {
+		return s.failure(conv, p, e)
+	}
+	return s.success(conv, p, req)
+}
+
+// Send first request of method m
+func (s *Server) start(conv *Conversation, p *Packet, m Method) Result {
+	if closer, ok := conv.method.(io.Closer); ok {
+		closer.Close()
+	}
+	conv.method = m
+	data, e := m.Start(conv)
+	if e != nil {
+		return s.failure(conv, p, e)
+	}
+	if s.Verbose {
+		s.Logger.Printf("eap: start identity=%s method=%s", conv.Identity, m.Type())
+	}
+	return s.challenge(conv, data)
+}
+
+// Next EAP-Request in Access-Challenge
+func (s *Server) challenge(conv *Conversation, data []byte) Result {
+	conv.id++
+	msg := &Packet{Code: Request, Identifier: conv.id, Type: conv.method.Type(), Data: data}
+	return Result{
+		Code:  radius.AccessChallenge,
+		Attrs: append(Attrs(msg.Encode()), radius.NewAttr(radius.State, []byte(conv.state), 0)),
+	}
+}
+
+// EAP-Success in Access-Accept with MPPE keys (if any)
+func (s *Server) success(conv *Conversation, p *Packet, req *radius.Packet) Result {
+	s.conversations.remove(conv)
+	if s.Verbose {
+		s.Logger.Printf("eap: success identity=%s method=%s", conv.Identity, conv.method.Type())
+	}
+
+	attrs := Attrs((&Packet{Code: Success, Identifier: p.Identifier}).Encode())
+	if conv.keys != nil {
+		send, recv := conv.keys(req.Secret(), req.Auth)
+		attrs = append(attrs, radius.VendorAttr{
+			Type:     radius.VendorSpecific,
+			VendorId: vendor.Microsoft,
+			Values: []radius.VendorAttrString{
+				radius.VendorAttrString{
+					Type:  vendor.MSMPPESendKey,
+					Value: send,
+				},
+				radius.VendorAttrString{
+					Type:  vendor.MSMPPERecvKey,
+					Value: recv,
+				},
+			},
+		}.Encode())
+	}
+	return Result{Code: radius.AccessAccept, Identity: conv.Identity, Attrs: attrs}
+}
+
+// EAP-Failure in Access-Reject
+func (s *Server) failure(conv *Conversation, p *Packet, e error) Result {
+	identity := ""
+	if conv != nil {
+		s.conversations.remove(conv)
+		identity = conv.Identity
+	}
+	s.Logger.Printf("eap: failure identity=%s e=%s", identity, e.Error())
+
+	msg := &Packet{Code: Failure, Identifier: p.Identifier}
+	return Result{Code: radius.AccessReject, Identity: identity, Attrs: Attrs(msg.Encode())}
+}
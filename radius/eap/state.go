@@ -0,0 +1,154 @@
+package eap
+
+import (
+	"crypto/rand"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mpdroog/radiusd/passwd"
+)
+
+// One authentication, identified by the RADIUS State attribute
+type Conversation struct {
+	lock     sync.Mutex
+	state    string
+	added    time.Time
+	Identity string // EAP-Response/Identity
+	id       uint8  // Identifier of the outstanding request
+	method   Method
+	keys     KeyFunc // Set by the method on success
+	server   *Server
+	closed   bool // Expired or finished, protected by lock
+}
+
+// Stored password of the identity if it can satisfy method
+func (c *Conversation) Password(method passwd.Method) (passwd.Password, error) {
+	stored, e := c.server.Password(c.Identity)
+	if e != nil {
+		return passwd.Password{}, e
+	}
+	if stored == "" {
+		return passwd.Password{}, ErrUnknownUser
+	}
+	pass, e := passwd.Parse(stored)
+	if e != nil {
+		return pass, e
+	}
+	if !pass.Supports(method) {
+		return pass, ErrFormat
+	}
+	return pass, nil
+}
+
+// Identifier of the outstanding request
+func (c *Conversation) Identifier() uint8 {
+	return c.id
+}
+
+// Encrypted MS-MPPE-Send-Key/MS-MPPE-Recv-Key for the Access-Accept
+func (c *Conversation) SetKeys(keys KeyFunc) {
+	c.keys = keys
+}
+
+// Release method resources (i.e. TLS handshake goroutine),
+// caller holds c.lock
+func (c *Conversation) close() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if closer, ok := c.method.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// Conversations by State, dropped after timeout
+type conversations struct {
+	timeout time.Duration
+	lock    sync.Mutex
+	entries map[string]*Conversation
+	sweep   time.Time
+}
+
+func newConversations(timeout time.Duration) *conversations {
+	return &conversations{
+		timeout: timeout,
+		entries: make(map[string]*Conversation),
+		sweep:   time.Now(),
+	}
+}
+
+// Start conversation with a random State
+func (c *conversations) add(conv *Conversation) error {
+	state := make([]byte, 16)
+	if _, e := rand.Read(state); e != nil {
+		return e
+	}
+	conv.state = string(state)
+	conv.added = time.Now()
+
+	c.lock.Lock()
+	expired := c.expire(conv.added)
+	c.entries[conv.state] = conv
+	c.lock.Unlock()
+
+	closeAll(expired)
+	return nil
+}
+
+// Conversation for State, nil if unknown or expired
+func (c *conversations) get(state []byte) *Conversation {
+	now := time.Now()
+	c.lock.Lock()
+	expired := c.expire(now)
+	conv, ok := c.entries[string(state)]
+	c.lock.Unlock()
+
+	closeAll(expired)
+	if !ok || now.Sub(conv.added) > c.timeout {
+		return nil
+	}
+	return conv
+}
+
+// Drop expired conversations at most once per timeout, caller
+// holds c.lock and closes the returned conversations after
+// releasing it (Handle holds conv.lock while calling remove)
+func (c *conversations) expire(now time.Time) []*Conversation {
+	if now.Sub(c.sweep) <= c.timeout {
+		return nil
+	}
+	var expired []*Conversation
+	for key, conv := range c.entries {
+		if now.Sub(conv.added) > c.timeout {
+			delete(c.entries, key)
+			expired = append(expired, conv)
+		}
+	}
+	c.sweep = now
+	return expired
+}
+
+func closeAll(list []*Conversation) {
+	for _, conv := range list {
+		conv.lock.Lock()
+		conv.close()
+		conv.lock.Unlock()
+	}
+}
+
+// Caller holds conv.lock
+func (c *conversations) remove(conv *Conversation) {
+	c.lock.Lock()
+	delete(c.entries, conv.state)
+	c.lock.Unlock()
+	conv.close()
+}
+
+// Amount of conversations in progress
+func (c *conversations) len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.entries)
+}
@@ -0,0 +1,372 @@
+// TLS driven over EAP fragments, shared by the tunnel methods
+// https://tools.ietf.org/html/rfc5216#section-3.1
+package eap
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Flags
+const (
+	flagLength = 0x80 // TLS Message Length included
+	flagMore   = 0x40 // More fragments
+	flagStart  = 0x20
+)
+
+// Max TLS data in one EAP packet, keeps Access-Challenge below MTU
+const fragmentSize = 1000
+
+// Max size of a reassembled TLS message
+const maxMessageLen = 64 * 1024
+
+// Label for the MSK (RFC5216 section 2.3)
+const mskLabel = "client EAP encryption"
+
+var ErrFragment = errors.New("eap-tls: invalid fragment")
+
+// Server certificate for the tunnel methods
+func TLSConfig(certFile string, keyFile string) (*tls.Config, error) {
+	cert, e := tls.LoadX509KeyPair(certFile, keyFile)
+	if e != nil {
+		return nil, e
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		// Key derivation for TLS1.3 differs (RFC9190)
+		MaxVersion:             tls.VersionTLS12,
+		SessionTicketsDisabled: true,
+	}, nil
+}
+
+// Copy of conf requiring client certificates signed by caFile
+// and not revoked by any of crlFiles (EAP-TLS)
+func ClientTLSConfig(conf *tls.Config, caFile string, crlFiles []string) (*tls.Config, error) {
+	raw, e := ioutil.ReadFile(caFile)
+	if e != nil {
+		return nil, e
+	}
+	var cas []*x509.Certificate
+	pool := x509.NewCertPool()
+	for {
+		block, rest := pem.Decode(raw)
+		if block == nil {
+			break
+		}
+		raw = rest
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		ca, e := x509.ParseCertificate(block.Bytes)
+		if e != nil {
+			return nil, e
+		}
+		cas = append(cas, ca)
+		pool.AddCert(ca)
+	}
+	if len(cas) == 0 {
+		return nil, errors.Errorf("No certificates in CA=%s", caFile)
+	}
+
+	out := conf.Clone()
+	out.ClientCAs = pool
+	out.ClientAuth = tls.RequireAndVerifyClientCert
+	if len(crlFiles) > 0 {
+		list, e := newCRLs(crlFiles, cas)
+		if e != nil {
+			return nil, e
+		}
+		out.VerifyPeerCertificate = list.verify
+	}
+	return out, nil
+}
+
+// No data buffered, retry after the next EAP round
+type errWouldBlock struct{}
+
+func (errWouldBlock) Error() string   { return "eap-tls: would block" }
+func (errWouldBlock) Timeout() bool   { return true }
+func (errWouldBlock) Temporary() bool { return true }
+
+type addr struct{}
+
+func (addr) Network() string { return "eap" }
+func (addr) String() string  { return "eap" }
+
+// In-memory net.Conn, the handshake runs in its own goroutine
+// and blocks on Read until the next EAP round delivers data.
+type tlsConn struct {
+	lock     sync.Mutex
+	cond     *sync.Cond
+	in       bytes.Buffer // received from the peer
+	out      bytes.Buffer // to send to the peer
+	waiting  bool         // Read blocked for input
+	finished bool         // Handshake returned
+	err      error        // Handshake error
+	closed   bool
+}
+
+func newTLSConn() *tlsConn {
+	c := &tlsConn{}
+	c.cond = sync.NewCond(&c.lock)
+	return c
+}
+
+func (c *tlsConn) Read(b []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for c.in.Len() == 0 {
+		if c.closed {
+			return 0, io.EOF
+		}
+		if c.finished {
+			// Application data is read from the EAP round itself
+			return 0, errWouldBlock{}
+		}
+		c.waiting = true
+		c.cond.Broadcast()
+		c.cond.Wait()
+	}
+	return c.in.Read(b)
+}
+
+func (c *tlsConn) Write(b []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return 0, io.ErrClosedPipe
+	}
+	return c.out.Write(b)
+}
+
+func (c *tlsConn) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closed = true
+	c.cond.Broadcast()
+	return nil
+}
+
+func (c *tlsConn) LocalAddr() net.Addr                { return addr{} }
+func (c *tlsConn) RemoteAddr() net.Addr               { return addr{} }
+func (c *tlsConn) SetDeadline(t time.Time) error      { return nil }
+func (c *tlsConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *tlsConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// Deliver in to the handshake and wait until it needs more
+// data or returned, returns what it wrote meanwhile.
+func (c *tlsConn) exchange(in []byte) ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.in.Write(in)
+	c.waiting = false
+	c.cond.Broadcast()
+	for !c.waiting && !c.finished && !c.closed {
+		c.cond.Wait()
+	}
+	return c.take(), c.err
+}
+
+// Handshake returned
+func (c *tlsConn) finish(e error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.finished = true
+	c.err = e
+	c.cond.Broadcast()
+}
+
+// Written data, caller holds lock
+func (c *tlsConn) take() []byte {
+	out := append([]byte{}, c.out.Bytes()...)
+	c.out.Reset()
+	return out
+}
+
+// Handle decrypted application data after the handshake, returns
+// data to encrypt for the peer or done when finished.
+type phase2Func func(app []byte) (reply []byte, done bool, e error)
+
+// TLS session over EAP-TLS style framing (flags, length, fragments)
+type tlsTunnel struct {
+	conn    *tlsConn
+	tls     *tls.Conn
+	started bool
+	version uint8  // Version bits in flags
+	recv    []byte // Fragments received so far
+	send    []byte // Remaining to send
+	first   bool   // Next fragment is the first of send
+}
+
+func newServerTunnel(conf *tls.Config, version uint8) *tlsTunnel {
+	conn := newTLSConn()
+	return &tlsTunnel{conn: conn, tls: tls.Server(conn, conf), version: version}
+}
+
+// EAP-Request with Start flag
+func (t *tlsTunnel) start() []byte {
+	return []byte{flagStart | t.version}
+}
+
+// Handshake finished
+func (t *tlsTunnel) established() bool {
+	t.conn.lock.Lock()
+	defer t.conn.lock.Unlock()
+	return t.conn.finished && t.conn.err == nil
+}
+
+// Flags only, acknowledges a fragment or the handshake
+func (t *tlsTunnel) ack() []byte {
+	return []byte{t.version}
+}
+
+// Queue out for the peer, returns first fragment
+func (t *tlsTunnel) queue(out []byte) []byte {
+	t.send = out
+	t.first = true
+	return t.fragment()
+}
+
+// Next fragment of t.send
+func (t *tlsTunnel) fragment() []byte {
+	n := len(t.send)
+	flags := t.version
+	var hdr []byte
+	if n > fragmentSize {
+		n = fragmentSize
+		flags |= flagMore
+		if t.first {
+			// First of a fragmented message includes Length
+			flags |= flagLength
+			hdr = make([]byte, 4)
+			binary.BigEndian.PutUint32(hdr, uint32(len(t.send)))
+		}
+	}
+	t.first = false
+
+	data := append([]byte{flags}, hdr...)
+	data = append(data, t.send[:n]...)
+	t.send = t.send[n:]
+	return data
+}
+
+// Process Type-Data from the peer, returns Type-Data for the next
+// request or done when phase2 finished.
+func (t *tlsTunnel) next(data []byte, phase2 phase2Func) ([]byte, bool, error) {
+	flags := uint8(0)
+	if len(data) > 0 {
+		flags = data[0]
+		data = data[1:]
+	}
+	if flags&flagLength != 0 {
+		if len(data) < 4 {
+			return nil, true, errors.Wrapf(ErrFragment, "length missing")
+		}
+		if n := binary.BigEndian.Uint32(data[0:4]); n > maxMessageLen {
+			return nil, true, errors.Wrapf(ErrFragment, "length=%d", n)
+		}
+		data = data[4:]
+	}
+
+	if len(t.send) > 0 {
+		// Peer acknowledges our previous fragment
+		if len(data) > 0 {
+			return nil, true, errors.Wrapf(ErrFragment, "expected ack")
+		}
+		return t.fragment(), false, nil
+	}
+
+	t.recv = append(t.recv, data...)
+	if len(t.recv) > maxMessageLen {
+		return nil, true, errors.Wrapf(ErrFragment, "message too long")
+	}
+	if flags&flagMore != 0 {
+		return t.ack(), false, nil
+	}
+	in := t.recv
+	t.recv = nil
+
+	if !t.established() {
+		if !t.started {
+			t.started = true
+			go func() {
+				t.conn.finish(t.tls.Handshake())
+			}()
+		}
+		out, e := t.conn.exchange(in)
+		if e != nil {
+			return nil, true, errors.Wrapf(e, "eap-tls: handshake")
+		}
+		if len(out) > 0 {
+			return t.queue(out), false, nil
+		}
+		if !t.established() {
+			return nil, true, errors.Wrapf(ErrFragment, "handshake stalled")
+		}
+		// Leftover data is still buffered in conn
+		in = nil
+	}
+	return t.phase2(in, phase2)
+}
+
+// Decrypt application data, encrypt the reply
+func (t *tlsTunnel) phase2(in []byte, phase2 phase2Func) ([]byte, bool, error) {
+	t.conn.lock.Lock()
+	t.conn.in.Write(in)
+	t.conn.lock.Unlock()
+
+	var app []byte
+	buf := make([]byte, 4096)
+	for {
+		n, e := t.tls.Read(buf)
+		app = append(app, buf[:n]...)
+		if e != nil {
+			if _, ok := e.(errWouldBlock); !ok {
+				return nil, true, errors.Wrapf(e, "eap-tls: read")
+			}
+			break
+		}
+	}
+
+	reply, done, e := phase2(app)
+	if done || e != nil {
+		return nil, true, e
+	}
+	if reply == nil {
+		return t.ack(), false, nil
+	}
+	if _, e := t.tls.Write(reply); e != nil {
+		return nil, true, errors.Wrapf(e, "eap-tls: write")
+	}
+	t.conn.lock.Lock()
+	out := t.conn.take()
+	t.conn.lock.Unlock()
+	return t.queue(out), false, nil
+}
+
+// MSK split in MS-MPPE-Recv-Key and MS-MPPE-Send-Key (RFC5216 section 2.3)
+func (t *tlsTunnel) keys(label string) (send []byte, recv []byte, e error) {
+	state := t.tls.ConnectionState()
+	msk, e := state.ExportKeyingMaterial(label, nil, 64)
+	if e != nil {
+		return nil, nil, e
+	}
+	return msk[32:64], msk[0:32], nil
+}
+
+func (t *tlsTunnel) Close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,263 @@
+// EAP-TTLS with PAP, CHAP and MS-CHAP as Diameter AVPs in the tunnel
+// https://tools.ietf.org/html/rfc5281
+package eap
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/radius/mschap"
+	"github.com/mpdroog/radiusd/radius/vendor"
+	"github.com/pkg/errors"
+)
+
+// AVP flags
+const (
+	avpVendor    = 0x80
+	avpMandatory = 0x40
+)
+
+// Labels for key derivation (RFC5281 section 8)
+const (
+	ttlsKeyLabel       = "ttls keying material"
+	ttlsChallengeLabel = "ttls challenge"
+)
+
+var ErrAVP = errors.New("eap-ttls: invalid AVP")
+
+// Diameter AVP (RFC5281 section 10)
+type avp struct {
+	Code   uint32
+	Flags  uint8
+	Vendor uint32
+	Data   []byte
+}
+
+func decodeAVPs(b []byte) ([]avp, error) {
+	var list []avp
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, errors.Wrapf(ErrAVP, "header truncated")
+		}
+		a := avp{Code: binary.BigEndian.Uint32(b[0:4]), Flags: b[4]}
+		n := int(b[5])<<16 | int(b[6])<<8 | int(b[7])
+		hdr := 8
+		if a.Flags&avpVendor != 0 {
+			hdr = 12
+		}
+		if n < hdr || n > len(b) {
+			return nil, errors.Wrapf(ErrAVP, "code=%d length=%d", a.Code, n)
+		}
+		if hdr == 12 {
+			a.Vendor = binary.BigEndian.Uint32(b[8:12])
+		}
+		a.Data = b[hdr:n]
+		list = append(list, a)
+
+		// Padded to a multiple of 4 octets
+		n = (n + 3) &^ 3
+		if n > len(b) {
+			n = len(b)
+		}
+		b = b[n:]
+	}
+	return list, nil
+}
+
+func (a avp) encode() []byte {
+	hdr := 8
+	if a.Vendor != 0 {
+		a.Flags |= avpVendor
+		hdr = 12
+	}
+	n := hdr + len(a.Data)
+	b := make([]byte, (n+3)&^3)
+	binary.BigEndian.PutUint32(b[0:4], a.Code)
+	b[4] = a.Flags
+	b[5], b[6], b[7] = byte(n>>16), byte(n>>8), byte(n)
+	if a.Vendor != 0 {
+		binary.BigEndian.PutUint32(b[8:12], a.Vendor)
+	}
+	copy(b[hdr:], a.Data)
+	return b
+}
+
+type ttlsMethod struct {
+	tunnel  *tlsTunnel
+	user    string
+	success bool // MS-CHAP2-Success sent, waiting for the peer
+}
+
+func (m *ttlsMethod) Type() Type {
+	return TTLS
+}
+
+func (m *ttlsMethod) Start(c *Conversation) ([]byte, error) {
+	m.tunnel = newServerTunnel(c.server.TLS, 0)
+	return m.tunnel.start(), nil
+}
+
+func (m *ttlsMethod) Next(c *Conversation, data []byte) ([]byte, bool, error) {
+	req, done, e := m.tunnel.next(data, func(app []byte) ([]byte, bool, error) {
+		return m.phase2(c, app)
+	})
+	if !done || e != nil {
+		return req, done, e
+	}
+
+	send, recv, e := m.tunnel.keys(ttlsKeyLabel)
+	if e != nil {
+		return nil, true, e
+	}
+	c.SetKeys(func(secret string, reqAuth []byte) ([]byte, []byte) {
+		return mschap.MppeKeys(secret, reqAuth, send, recv)
+	})
+	c.Identity = m.user
+	return nil, true, nil
+}
+
+func (m *ttlsMethod) Close() error {
+	if m.tunnel == nil {
+		return nil
+	}
+	return m.tunnel.Close()
+}
+
+func (m *ttlsMethod) phase2(c *Conversation, app []byte) ([]byte, bool, error) {
+	if m.success {
+		// Peer verified our MS-CHAP2-Success
+		return nil, true, nil
+	}
+	if len(app) == 0 {
+		// Handshake finished, peer sends the AVPs next
+		return nil, false, nil
+	}
+	avps, e := decodeAVPs(app)
+	if e != nil {
+		return nil, true, e
+	}
+
+	// Random secret, the inner request never leaves the process
+	secret := make([]byte, 16)
+	auth := make([]byte, 16)
+	if _, e := rand.Read(secret); e != nil {
+		return nil, true, e
+	}
+	if _, e := rand.Read(auth); e != nil {
+		return nil, true, e
+	}
+	inner := radius.NewRequest(radius.AccessRequest, hex.EncodeToString(secret), nil)
+	inner.Auth = auth
+
+	for _, a := range avps {
+		attr, e := m.attr(inner, a)
+		if e != nil {
+			return nil, true, e
+		}
+		if attr != nil {
+			inner.Attrs = append(inner.Attrs, attr)
+		}
+	}
+	if !inner.HasAttr(radius.UserName) {
+		return nil, true, errors.New("eap-ttls: User-Name missing")
+	}
+	m.user = string(inner.Attr(radius.UserName))
+	if e := m.challenge(inner); e != nil {
+		return nil, true, e
+	}
+
+	reply, e := c.server.Inner(inner)
+	if e != nil {
+		return nil, true, errors.Wrapf(ErrAuth, "inner user=%s e=%s", m.user, e.Error())
+	}
+	success := msAttr(reply, vendor.MSCHAP2Success)
+	if success == nil {
+		return nil, true, nil
+	}
+	// MS-CHAPv2 authenticates the server as well (RFC5281 section 11.2.4)
+	m.success = true
+	return avp{Code: vendor.MSCHAP2Success, Flags: avpMandatory, Vendor: vendor.Microsoft, Data: success}.encode(), false, nil
+}
+
+// Inner RADIUS attribute for a, nil to ignore
+func (m *ttlsMethod) attr(inner *radius.Packet, a avp) (radius.AttrEncoder, error) {
+	if a.Code > 255 || len(a.Data) > 253 || radius.AttributeType(a.Code) == radius.EAPMessage {
+		if a.Flags&avpMandatory != 0 {
+			return nil, errors.Wrapf(ErrAVP, "unsupported mandatory code=%d vendor=%d", a.Code, a.Vendor)
+		}
+		return nil, nil
+	}
+	if a.Vendor != 0 {
+		return radius.VendorAttr{
+			Type:     radius.VendorSpecific,
+			VendorId: a.Vendor,
+			Values: []radius.VendorAttrString{
+				radius.VendorAttrString{Type: vendor.AttributeType(a.Code), Value: a.Data},
+			},
+		}.Encode(), nil
+	}
+	if radius.AttributeType(a.Code) == radius.UserPassword {
+		// Cleartext in the tunnel, hidden like a NAS would
+		pass := string(bytes.TrimRight(a.Data, "\x00"))
+		if len(pass) > 128 {
+			return nil, errors.Wrapf(ErrAVP, "User-Password too long")
+		}
+		return radius.NewAttr(radius.UserPassword, radius.EncryptPassword(pass, inner), 0), nil
+	}
+	return radius.NewAttr(radius.AttributeType(a.Code), a.Data, 0), nil
+}
+
+// CHAP and MS-CHAP challenges must come from the TLS session
+// to prevent replays (RFC5281 section 11.2)
+func (m *ttlsMethod) challenge(inner *radius.Packet) error {
+	var challenge, ident []byte
+	if inner.HasAttr(radius.CHAPPassword) {
+		if !inner.HasAttr(radius.CHAPChallenge) {
+			return errors.New("eap-ttls: CHAP-Challenge missing")
+		}
+		challenge = inner.Attr(radius.CHAPChallenge)
+		ident = inner.Attr(radius.CHAPPassword)
+	} else if c := msAttr(inner.Attrs, vendor.MSCHAPChallenge); c != nil {
+		challenge = c
+		ident = msAttr(inner.Attrs, vendor.MSCHAP2Response)
+		if ident == nil {
+			ident = msAttr(inner.Attrs, vendor.MSCHAPResponse)
+		}
+	} else {
+		return nil
+	}
+
+	state := m.tunnel.tls.ConnectionState()
+	expect, e := state.ExportKeyingMaterial(ttlsChallengeLabel, nil, 17)
+	if e != nil {
+		return e
+	}
+	if len(ident) == 0 || !bytes.Equal(challenge, expect[:16]) || ident[0] != expect[16] {
+		return errors.New("eap-ttls: challenge not derived from tunnel")
+	}
+	return nil
+}
+
+// Value of the first Microsoft attribute t in attrs
+func msAttr(attrs []radius.AttrEncoder, t vendor.AttributeType) []byte {
+	for _, attr := range attrs {
+		if attr.Type() != radius.VendorSpecific || len(attr.Bytes()) < 4 {
+			continue
+		}
+		b := attr.Bytes()
+		if binary.BigEndian.Uint32(b[0:4]) != vendor.Microsoft {
+			continue
+		}
+		b = b[4:]
+		for len(b) >= 2 && int(b[1]) >= 2 && int(b[1]) <= len(b) {
+			if vendor.AttributeType(b[0]) == t {
+				return b[2:b[1]]
+			}
+			b = b[b[1]:]
+		}
+	}
+	return nil
+}
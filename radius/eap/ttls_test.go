@@ -0,0 +1,153 @@
+package eap
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"testing"
+
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/radius/mschap"
+	"github.com/mpdroog/radiusd/radius/vendor"
+)
+
+// Inner checks like handlers.Auth for user/pass
+func testInner(req *radius.Packet) ([]radius.AttrEncoder, error) {
+	if string(req.Attr(radius.UserName)) != "user" {
+		return nil, errors.New("No such user")
+	}
+	if req.HasAttr(radius.UserPassword) {
+		if radius.DecryptPassword(req.Attr(radius.UserPassword), req) != "pass" {
+			return nil, errors.New("Invalid password")
+		}
+		return nil, nil
+	}
+
+	challenge := msAttr(req.Attrs, vendor.MSCHAPChallenge)
+	res := msAttr(req.Attrs, vendor.MSCHAP2Response)
+	enc, e := mschap.Encryptv2(challenge, res[2:18], "user", "pass")
+	if e != nil {
+		return nil, e
+	}
+	if !bytes.Equal(enc.ChallengeResponse, res[26:50]) {
+		return nil, errors.New("Invalid password")
+	}
+	return []radius.AttrEncoder{radius.VendorAttr{
+		Type:     radius.VendorSpecific,
+		VendorId: vendor.Microsoft,
+		Values: []radius.VendorAttrString{
+			radius.VendorAttrString{Type: vendor.MSCHAP2Success, Value: append([]byte{res[0]}, enc.AuthenticatorResponse...)},
+		},
+	}.Encode()}, nil
+}
+
+func testTTLS(t *testing.T, avps func(peer *tlsPeer) []avp) (Result, *tlsPeer, *radius.Packet) {
+	cert, pool := testCert(t)
+	s := testServer(TTLS)
+	s.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MaxVersion: tls.VersionTLS12, SessionTicketsDisabled: true}
+	s.Inner = testInner
+
+	var peer *tlsPeer
+	sent := false
+	peer = newTLSPeer(t, TTLS, &tls.Config{RootCAs: pool}, func(app []byte) ([]byte, bool, error) {
+		if sent {
+			// MS-CHAP2-Success, ack
+			return nil, false, nil
+		}
+		sent = true
+		var b []byte
+		for _, a := range avps(peer) {
+			b = append(b, a.encode()...)
+		}
+		return b, false, nil
+	})
+	res, req := testTunnel(t, s, peer)
+	return res, peer, req
+}
+
+func papAVPs(pass string) func(peer *tlsPeer) []avp {
+	return func(peer *tlsPeer) []avp {
+		return []avp{
+			{Code: uint32(radius.UserName), Flags: avpMandatory, Data: []byte("user")},
+			{Code: uint32(radius.UserPassword), Flags: avpMandatory, Data: []byte(pass + "\x00\x00")},
+		}
+	}
+}
+
+func TestTTLSPAP(t *testing.T) {
+	res, peer, req := testTTLS(t, papAVPs("pass"))
+	if res.Code != radius.AccessAccept || res.Identity != "user" {
+		t.Fatalf("Expected accept, found=%s identity=%s", res.Code, res.Identity)
+	}
+	testKeys(t, res, peer, req, ttlsKeyLabel)
+
+	if res, _, _ := testTTLS(t, papAVPs("wrong")); res.Code != radius.AccessReject {
+		t.Fatalf("Expected reject, found=%s", res.Code)
+	}
+}
+
+func mschapAVPs(implicit bool) func(peer *tlsPeer) []avp {
+	return func(peer *tlsPeer) []avp {
+		state := peer.tunnel.tls.ConnectionState()
+		c, e := state.ExportKeyingMaterial(ttlsChallengeLabel, nil, 17)
+		if e != nil {
+			panic(e)
+		}
+		if !implicit {
+			c[0]++
+		}
+		peerChallenge := []byte("fedcba9876543210")
+		enc, e := mschap.Encryptv2(c[:16], peerChallenge, "user", "pass")
+		if e != nil {
+			panic(e)
+		}
+		res := append([]byte{c[16], 0}, peerChallenge...)
+		res = append(res, make([]byte, 8)...)
+		res = append(res, enc.ChallengeResponse...)
+		return []avp{
+			{Code: uint32(radius.UserName), Flags: avpMandatory, Data: []byte("user")},
+			{Code: uint32(vendor.MSCHAPChallenge), Flags: avpMandatory, Vendor: vendor.Microsoft, Data: c[:16]},
+			{Code: uint32(vendor.MSCHAP2Response), Flags: avpMandatory, Vendor: vendor.Microsoft, Data: res},
+		}
+	}
+}
+
+func TestTTLSMSCHAPv2(t *testing.T) {
+	res, peer, req := testTTLS(t, mschapAVPs(true))
+	if res.Code != radius.AccessAccept || res.Identity != "user" {
+		t.Fatalf("Expected accept, found=%s identity=%s", res.Code, res.Identity)
+	}
+	testKeys(t, res, peer, req, ttlsKeyLabel)
+
+	// Challenge not derived from the tunnel
+	if res, _, _ := testTTLS(t, mschapAVPs(false)); res.Code != radius.AccessReject {
+		t.Fatalf("Expected reject, found=%s", res.Code)
+	}
+}
+
+func TestAVP(t *testing.T) {
+	in := []avp{
+		{Code: 1, Flags: avpMandatory, Data: []byte("user")},
+		{Code: 11, Flags: avpMandatory | avpVendor, Vendor: vendor.Microsoft, Data: []byte("0123456789abcdef")},
+		{Code: 2, Data: []byte("pass1")},
+	}
+	var b []byte
+	for _, a := range in {
+		b = append(b, a.encode()...)
+	}
+	out, e := decodeAVPs(b)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(out) != len(in) {
+		t.Fatalf("Expected %d AVPs, found=%d", len(in), len(out))
+	}
+	for i := range in {
+		if out[i].Code != in[i].Code || out[i].Vendor != in[i].Vendor || !bytes.Equal(out[i].Data, in[i].Data) {
+			t.Fatalf("AVP %d mismatch %+v", i, out[i])
+		}
+	}
+	if _, e := decodeAVPs(b[:len(b)-9]); e == nil {
+		t.Fatal("Expected error for truncated AVP")
+	}
+}
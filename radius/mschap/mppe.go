@@ -6,6 +6,12 @@ import (
 	"strings"
 )
 
+// MS-MPPE-Encryption-Types (RFC2548 section 2.4.3)
+var (
+	MPPEv1Types   = []byte{0x0, 0x0, 0x0, 0x06} // 40-bit+128-bit
+	MPPEv1TypesNT = []byte{0x0, 0x0, 0x0, 0x04} // 128-bit
+)
+
 func desHash(clear []byte) ([]byte, error) {
 	block, e := des.NewCipher(strToKey(clear)) // clear=secret
 	if e != nil {
@@ -52,15 +58,22 @@ func lmPasswordHash(pass string) ([]byte, error) {
 }
 
 func Mppev1(pass string) ([]byte, error) {
-	var res []byte
-	passHash := ntPasswordHash(ntPassword(pass))
-
 	lm, e := lmPasswordHash(pass)
 	if e != nil {
 		return nil, e
 	}
-	lm = lm[:8]
+	return mppev1(lm[:8], NTHash(pass)), nil
+}
 
+// Mppev1 with a stored NT-Hash, the LM-Key for 40/56-bit keys
+// (RFC3079 section 2.1/2.2) needs the cleartext so it's zero,
+// only offer 128-bit (MPPEv1TypesNT)
+func Mppev1NT(passHash []byte) []byte {
+	return mppev1(make([]byte, 8), passHash)
+}
+
+func mppev1(lm []byte, passHash []byte) []byte {
+	var res []byte
 	res = append(res, lm...)
 	/*
 	 *	According to RFC 2548 we
@@ -74,5 +87,5 @@ func Mppev1(pass string) ([]byte, error) {
 	// padding
 	res = append(res, []byte{0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}...)
 
-	return res, nil
+	return res
 }
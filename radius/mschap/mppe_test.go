@@ -59,3 +59,29 @@ func TestMppev1Prod(t *testing.T) {
 		t.Fatal(fmt.Printf("TestAnotherEncrypt bytes wrong. expect=%d found=%d", expect, res))
 	}
 }
+
+// RFC3079 section 3.1 (password "clientPass")
+func TestMppev1RFC3079(t *testing.T) {
+	lm := []byte{0x76, 0xa1, 0x52, 0x93, 0x60, 0x96, 0xd7, 0x83}
+	hashHash := []byte{
+		0x41, 0xc0, 0x0c, 0x58, 0x4b, 0xd2, 0xd9, 0x1c,
+		0x40, 0x17, 0xa2, 0xa1, 0x2f, 0xa5, 0x9f, 0x3f,
+	}
+	pad := make([]byte, 8)
+
+	res, e := Mppev1("clientPass")
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := append(append(append([]byte{}, lm...), hashHash...), pad...)
+	if bytes.Compare(res, expect) != 0 {
+		t.Fatalf("Mppev1 bytes wrong. expect=%x found=%x", expect, res)
+	}
+
+	// NT-Hash only, no LM-Key
+	res = Mppev1NT(NTHash("clientPass"))
+	expect = append(append(make([]byte, 8), hashHash...), pad...)
+	if bytes.Compare(res, expect) != 0 {
+		t.Fatalf("Mppev1NT bytes wrong. expect=%x found=%x", expect, res)
+	}
+}
@@ -92,8 +92,12 @@ func getAsymmetricStartKey(masterKey []byte, sessKeyLen int, isSend bool) []byte
 }
 
 func masterKeys(pass string, ntResponse []byte) ([]byte, []byte) {
+	return masterKeysNT(NTHash(pass), ntResponse)
+}
+
+func masterKeysNT(passHash []byte, ntResponse []byte) ([]byte, []byte) {
 	// PasswordHashHash( NtPasswordHash(Password, PasswordHash) )
-	hashHash := hashNtPasswordHash(ntPasswordHash(ntPassword(pass)))
+	hashHash := hashNtPasswordHash(passHash)
 	// GetMasterKey(PasswordHashHash, NtResponse, MasterKey)
 	masterKey := getMasterKey(hashHash, ntResponse)
 
@@ -223,7 +227,17 @@ func salt(offset uint8) []byte {
 }
 
 func Mmpev2(secret string, pass string, reqAuth []byte, ntResponse []byte) ([]byte, []byte) {
-	send, recv := masterKeys(pass, ntResponse)
+	return Mmpev2NT(secret, NTHash(pass), reqAuth, ntResponse)
+}
+
+// Mmpev2 with a stored NT-Hash instead of the cleartext
+func Mmpev2NT(secret string, passHash []byte, reqAuth []byte, ntResponse []byte) ([]byte, []byte) {
+	send, recv := masterKeysNT(passHash, ntResponse)
+	return MppeKeys(secret, reqAuth, send, recv)
+}
+
+// Encrypt MS-MPPE-Send-Key and MS-MPPE-Recv-Key (i.e. from a TLS tunnel)
+func MppeKeys(secret string, reqAuth []byte, send []byte, recv []byte) ([]byte, []byte) {
 	sendEnc := tunnelPass(secret, send, reqAuth, salt(0))
 	recvEnc := tunnelPass(secret, recv, reqAuth, salt(1))
 
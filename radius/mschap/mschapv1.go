@@ -91,8 +91,17 @@ func ntChallengeResponse(challenge []byte, passHash []byte) ([]byte, error) {
 	return res, nil
 }
 
+// NT-Hash of cleartext pass (MD4 of UCS-2)
+func NTHash(pass string) []byte {
+	return ntPasswordHash(ntPassword(pass))
+}
+
 // Encrypt MSCHAPv1 challenge+pass and return challengeresponse
 func Encryptv1(challenge []byte, pass string) ([]byte, error) {
-	passHash := ntPasswordHash(ntPassword(pass))
+	return Encryptv1NT(challenge, NTHash(pass))
+}
+
+// Encryptv1 with a stored NT-Hash instead of the cleartext
+func Encryptv1NT(challenge []byte, passHash []byte) ([]byte, error) {
 	return ntChallengeResponse(challenge, passHash)
 }
@@ -30,19 +30,23 @@ func challengeHash(peerChallenge []byte, authChallenge []byte, userName []byte)
 
 // GenerateNTResponse, GenerateAuthenticatorResponse
 func Encryptv2(authenticatorChallenge []byte, peerChallenge []byte, username string, pass string) (*Res, error) {
+	return Encryptv2NT(authenticatorChallenge, peerChallenge, username, NTHash(pass))
+}
+
+// Encryptv2 with a stored NT-Hash instead of the cleartext
+func Encryptv2NT(authenticatorChallenge []byte, peerChallenge []byte, username string, passHash []byte) (*Res, error) {
 	var (
 		out Res
 		e   error
 	)
 
 	challenge := challengeHash(peerChallenge, authenticatorChallenge, []byte(username))
-	passHash := ntPasswordHash(ntPassword(pass))
 
 	out.ChallengeResponse, e = ntChallengeResponse(challenge, passHash)
 	if e != nil {
 		return nil, e
 	}
-	out.AuthenticatorResponse = authResponse(pass, out.ChallengeResponse, peerChallenge, authenticatorChallenge, username)
+	out.AuthenticatorResponse = authResponse(passHash, out.ChallengeResponse, peerChallenge, authenticatorChallenge, username)
 
 	return &out, nil
 }
@@ -56,7 +60,7 @@ func hashNtPasswordHash(hash []byte) []byte {
 }
 
 // GenerateAuthenticatorResponse
-func authResponse(pass string, ntResponse []byte, peerChallenge []byte, authChallenge []byte, userName string) string {
+func authResponse(passHash []byte, ntResponse []byte, peerChallenge []byte, authChallenge []byte, userName string) string {
 	var x []byte
 	{
 		magic := []byte{
@@ -65,7 +69,7 @@ func authResponse(pass string, ntResponse []byte, peerChallenge []byte, authChal
 			0x6E, 0x74, 0x20, 0x73, 0x69, 0x67, 0x6E, 0x69, 0x6E, 0x67,
 			0x20, 0x63, 0x6F, 0x6E, 0x73, 0x74, 0x61, 0x6E, 0x74,
 		}
-		hashHash := hashNtPasswordHash(ntPasswordHash(ntPassword(pass)))
+		hashHash := hashNtPasswordHash(passHash)
 
 		enc := sha1.New()
 		enc.Write(hashHash)
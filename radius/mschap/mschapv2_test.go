@@ -114,7 +114,7 @@ func TestAuthResponse2(t *testing.T) {
 	}
 
 	expect := "S=407A5589115FD0D6209F510FE9C04566932CDA56"
-	res := authResponse("clientPass", ntResponse, peerChallenge, authChallenge, "User")
+	res := authResponse(NTHash("clientPass"), ntResponse, peerChallenge, authChallenge, "User")
 	if res != expect {
 		t.Fatal(fmt.Printf("TestAuthResponse2 res wrong. expect=%s found=%s", expect, res))
 	}
@@ -7,13 +7,15 @@ import "fmt"
 const (
 	_PacketCode_name_0 = "AccessRequestAccessAcceptAccessRejectAccountingRequestAccountingResponse"
 	_PacketCode_name_1 = "AccessChallengeStatusServerStatusClient"
-	_PacketCode_name_2 = "Reserved"
+	_PacketCode_name_2 = "DisconnectRequestDisconnectACKDisconnectNAKCoARequestCoAACKCoANAK"
+	_PacketCode_name_3 = "Reserved"
 )
 
 var (
 	_PacketCode_index_0 = [...]uint8{0, 13, 25, 37, 54, 72}
 	_PacketCode_index_1 = [...]uint8{0, 15, 27, 39}
-	_PacketCode_index_2 = [...]uint8{0, 8}
+	_PacketCode_index_2 = [...]uint8{0, 17, 30, 43, 53, 59, 65}
+	_PacketCode_index_3 = [...]uint8{0, 8}
 )
 
 func (i PacketCode) String() string {
@@ -24,8 +26,11 @@ func (i PacketCode) String() string {
 	case 11 <= i && i <= 13:
 		i -= 11
 		return _PacketCode_name_1[_PacketCode_index_1[i]:_PacketCode_index_1[i+1]]
+	case 40 <= i && i <= 45:
+		i -= 40
+		return _PacketCode_name_2[_PacketCode_index_2[i]:_PacketCode_index_2[i+1]]
 	case i == 255:
-		return _PacketCode_name_2
+		return _PacketCode_name_3
 	default:
 		return fmt.Sprintf("PacketCode(%d)", i)
 	}
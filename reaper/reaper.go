@@ -0,0 +1,64 @@
+// Remove sessions the NAS stopped sending Interim-Updates for
+// (i.e. it crashed without Accounting-Off)
+package reaper
+
+import (
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/radius"
+)
+
+type Storage interface {
+	SelectStaleSessions(before int64) ([]model.Session, error)
+	ReapSession(name string, sessID string, nasIP string, cause uint32, before int64) (bool, error)
+}
+
+// Archive and remove sessions without update for maxAge, returns
+// the amount reaped by us (other nodes may reap the rest)
+func Reap(storage Storage, maxAge time.Duration, verbose bool, logger *log.Logger) (int, error) {
+	before := time.Now().Add(-maxAge).Unix()
+	sessions, e := storage.SelectStaleSessions(before)
+	if e != nil {
+		return 0, e
+	}
+
+	count := 0
+	for _, sess := range sessions {
+		ok, e := storage.ReapSession(sess.User, sess.SessionID, sess.NasIP, radius.TerminateLostService, before)
+		if e != nil {
+			// i.e. lost the race to another node
+			logger.Printf("WARN: reaper sess=%s user=%s e=%s", sess.SessionID, sess.User, e.Error())
+			continue
+		}
+		if !ok {
+			continue
+		}
+		count++
+		if verbose {
+			logger.Printf("reaper sess=%s for user=%s on nasIP=%s", sess.SessionID, sess.User, sess.NasIP)
+		}
+	}
+	return count, nil
+}
+
+// Reap every interval, nodes start at a random offset
+func Loop(storage Storage, maxAge time.Duration, every time.Duration, verbose bool, logger *log.Logger) {
+	time.Sleep(time.Duration(rand.Int63n(int64(every))))
+	if verbose {
+		logger.Printf("Reaper every: %s for sessions idle %s", every.String(), maxAge.String())
+	}
+
+	for range time.Tick(every) {
+		count, e := Reap(storage, maxAge, verbose, logger)
+		if e != nil {
+			logger.Printf("WARN: reaper e=" + e.Error())
+			continue
+		}
+		if verbose && count > 0 {
+			logger.Printf("reaper removed %d sessions", count)
+		}
+	}
+}
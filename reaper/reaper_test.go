@@ -0,0 +1,89 @@
+package reaper_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	S "sync"
+	"testing"
+	"time"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/reaper"
+	"github.com/mpdroog/radiusd/storage"
+)
+
+var logger = log.New(ioutil.Discard, "", 0)
+
+func testStorage(t *testing.T, sessions ...string) *storage.Memory {
+	store := storage.NewMemory()
+	store.AddUser("herp", model.User{})
+	for _, sessID := range sessions {
+		if e := store.CreateSession("herp", sessID, "127.0.0.1", "10.0.0.2", "1.2.3.4"); e != nil {
+			t.Fatal(e)
+		}
+	}
+	return store
+}
+
+// Timestamps are in seconds
+func nextSecond() {
+	time.Sleep(time.Until(time.Unix(time.Now().Unix()+1, 0)))
+}
+
+func TestReap(t *testing.T) {
+	store := testStorage(t, "stale", "alive")
+	nextSecond()
+	// Interim-Update
+	if e := store.UpdateSession("herp", "alive", "127.0.0.1", 10, 10, 1, 1, 60, 0); e != nil {
+		t.Fatal(e)
+	}
+
+	count, e := reaper.Reap(store, 0, false, logger)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if count != 1 {
+		t.Fatalf("Expected 1 reaped, found=%d", count)
+	}
+	if ok, _ := store.IsSessionExists("herp", "alive", "127.0.0.1"); !ok {
+		t.Fatal("Updated session reaped")
+	}
+	log := store.SessionLog()
+	if len(log) != 1 || log[0].SessionID != "stale" || log[0].TerminateCause != radius.TerminateLostService {
+		t.Fatalf("Stale session not archived, log=%+v", log)
+	}
+}
+
+func TestReapConcurrent(t *testing.T) {
+	var sessions []string
+	for i := 0; i < 100; i++ {
+		sessions = append(sessions, fmt.Sprintf("sess%d", i))
+	}
+	store := testStorage(t, sessions...)
+	nextSecond()
+
+	// Two nodes sharing storage
+	var wg S.WaitGroup
+	counts := make([]int, 2)
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			count, e := reaper.Reap(store, 0, false, logger)
+			if e != nil {
+				t.Error(e)
+			}
+			counts[i] = count
+		}(i)
+	}
+	wg.Wait()
+
+	if counts[0]+counts[1] != len(sessions) {
+		t.Fatalf("Expected %d reaped, found=%v", len(sessions), counts)
+	}
+	if log := store.SessionLog(); len(log) != len(sessions) {
+		t.Fatalf("Expected %d archived, found=%d", len(sessions), len(log))
+	}
+}
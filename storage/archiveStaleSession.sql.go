@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const archiveStaleSession = "INSERT INTO session_log (\n  assigned_ip,\n  bytes_in,\n  bytes_out,\n  client_ip,\n  nas_ip,\n  packets_in,\n  packets_out,\n  session_id,\n  session_time,\n  user,\n  time_added,\n  terminate_cause\n  )\nSELECT\n  assigned_ip,\n  bytes_in,\n  bytes_out,\n  client_ip,\n  nas_ip,\n  packets_in,\n  packets_out,\n  session_id,\n  session_time,\n  user,\n  time_added,\n  ?\nFROM session\nWHERE user = ?\n  AND session_id = ?\n  AND nas_ip = ?\n  AND time_updated < ?\n  AND time_added < ?"
@@ -0,0 +1,2 @@
+DELETE FROM totp_challenge
+WHERE state = ?
\ No newline at end of file
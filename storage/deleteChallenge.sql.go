@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const deleteChallenge = "DELETE FROM totp_challenge\nWHERE state = ?"
@@ -0,0 +1,3 @@
+DELETE FROM	session
+WHERE nas_ip = ?
+  AND time_added < ?
\ No newline at end of file
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const deleteNASSessions = "DELETE FROM\tsession\nWHERE nas_ip = ?\n  AND time_added < ?"
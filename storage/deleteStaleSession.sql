@@ -0,0 +1,6 @@
+DELETE FROM	session
+WHERE user = ?
+  AND session_id = ?
+  AND nas_ip = ?
+  AND time_updated < ?
+  AND time_added < ?
\ No newline at end of file
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const deleteStaleSession = "DELETE FROM\tsession\nWHERE user = ?\n  AND session_id = ?\n  AND nas_ip = ?\n  AND time_updated < ?\n  AND time_added < ?"
@@ -0,0 +1,2 @@
+DELETE FROM totp_challenge
+WHERE time_added < ?
\ No newline at end of file
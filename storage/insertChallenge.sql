@@ -0,0 +1,2 @@
+INSERT INTO totp_challenge (state, user, time_added)
+VALUES (?, ?, ?)
\ No newline at end of file
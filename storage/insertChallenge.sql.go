@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const insertChallenge = "INSERT INTO totp_challenge (state, user, time_added)\nVALUES (?, ?, ?)"
@@ -0,0 +1,4 @@
+INSERT INTO quota_action
+  (user, session_id, nas_ip, action, error, hostname, time_added)
+VALUES
+  (?, ?, ?, ?, ?, ?, ?)
\ No newline at end of file
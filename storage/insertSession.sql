@@ -9,5 +9,6 @@ INSERT INTO	session (
   bytes_out,
   packets_in,
   packets_out,
-  session_time
- ) VALUES (?, ?, ?, ?, ?, ?, 0, 0, 0, 0, 0)
\ No newline at end of file
+  session_time,
+  time_updated
+ ) VALUES (?, ?, ?, ?, ?, ?, 0, 0, 0, 0, 0, ?)
\ No newline at end of file
@@ -0,0 +1,377 @@
+package storage
+
+import (
+	S "sync"
+	"time"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/sync"
+	"github.com/pkg/errors"
+)
+
+// Row in accounting (user+date+hostname)
+type Acct struct {
+	User       string
+	Date       string
+	Hostname   string
+	BytesIn    int64
+	BytesOut   int64
+	PacketsIn  int64
+	PacketsOut int64
+}
+
+// Row in quota_action
+type QuotaAction struct {
+	User      string
+	SessionID string
+	NasIP     string
+	Action    string
+	Error     string
+	Hostname  string
+}
+
+// Row in session_log
+type SessionLog struct {
+	model.Session
+	TerminateCause uint32 // 0 if closed by the NAS
+}
+
+type memChallenge struct {
+	user  string
+	added int64
+}
+
+type memSession struct {
+	model.Session
+	added   int64
+	updated int64
+}
+
+// Storage without database for tests and demos, state is lost
+// on exit. Users and NAS are added with AddUser and AddNAS.
+type Memory struct {
+	lock     S.Mutex
+	users    map[string]model.User
+	nas      []model.NAS
+	sessions map[string]memSession // user+sessID+nasIP
+	log      []SessionLog
+	acct     []Acct
+	quota    []QuotaAction
+	states   map[string]memChallenge
+	counters map[string]uint64 // User => last TOTP counter
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		users:    make(map[string]model.User),
+		sessions: make(map[string]memSession),
+		states:   make(map[string]memChallenge),
+		counters: make(map[string]uint64),
+	}
+}
+
+func memKey(name string, sessID string, nasIP string) string {
+	return name + "\x00" + sessID + "\x00" + nasIP
+}
+
+// Add or replace user, Ok is set
+func (s *Memory) AddUser(name string, user model.User) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	user.Ok = true
+	s.users[name] = user
+}
+
+func (s *Memory) AddNAS(nas model.NAS) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.nas = append(s.nas, nas)
+}
+
+// Copy of session_log
+func (s *Memory) SessionLog() []SessionLog {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]SessionLog{}, s.log...)
+}
+
+// Copy of accounting
+func (s *Memory) Accounting() []Acct {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Acct{}, s.acct...)
+}
+
+// Copy of quota_action
+func (s *Memory) QuotaActions() []QuotaAction {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]QuotaAction{}, s.quota...)
+}
+
+func (s *Memory) GetUser(name string) (model.User, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.users[name], nil
+}
+
+func (s *Memory) CountSessions(name string) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	count := 0
+	for _, sess := range s.sessions {
+		if sess.User == name {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Memory) GetLimits(name string) (model.UserLimits, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.users[name]; !ok {
+		return model.UserLimits{}, model.ErrNoRows
+	}
+	return model.UserLimits{Exists: true}, nil
+}
+
+func (s *Memory) GetNASList() ([]model.NAS, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]model.NAS{}, s.nas...), nil
+}
+
+func (s *Memory) IsSessionExists(name string, sessID string, nasIP string) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.sessions[memKey(name, sessID, nasIP)]
+	return ok, nil
+}
+
+func (s *Memory) GetSession(name string, sessID string, nasIP string) (model.Session, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sess, ok := s.sessions[memKey(name, sessID, nasIP)]
+	if !ok {
+		return model.Session{}, model.ErrNoRows
+	}
+	return sess.Session, nil
+}
+
+func (s *Memory) CreateSession(name string, sessID string, nasIP string, assignedIP string, clientIP string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := memKey(name, sessID, nasIP)
+	if _, ok := s.sessions[key]; ok {
+		return errors.Wrapf(model.ErrCreateSession, "sess=%s user=%s", sessID, name)
+	}
+	now := time.Now().Unix()
+	s.sessions[key] = memSession{
+		Session: model.Session{
+			SessionID:  sessID,
+			User:       name,
+			NasIP:      nasIP,
+			AssignedIP: assignedIP,
+			ClientIP:   clientIP,
+		},
+		added:   now,
+		updated: now,
+	}
+	return nil
+}
+
+func (s *Memory) UpdateSession(name string, sessID string, nasIP string, rx int64, tx int64, rxPackets int, txPackets int, duration int, prevDuration int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := memKey(name, sessID, nasIP)
+	sess, ok := s.sessions[key]
+	if !ok || int(sess.SessionTime) != prevDuration {
+		return errors.Wrapf(model.ErrUpdateSession, "sess=%s user=%s", sessID, name)
+	}
+	sess.BytesIn, sess.BytesOut = uint64(rx), uint64(tx)
+	sess.PacketsIn, sess.PacketsOut = uint32(rxPackets), uint32(txPackets)
+	sess.SessionTime = uint32(duration)
+	sess.updated = time.Now().Unix()
+	s.sessions[key] = sess
+	return nil
+}
+
+func (s *Memory) FinishSession(name string, sessID string, nasIP string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := memKey(name, sessID, nasIP)
+	if _, ok := s.sessions[key]; !ok {
+		return errors.Wrapf(model.ErrFinishSession, "sess=%s user=%s", sessID, name)
+	}
+	delete(s.sessions, key)
+	return nil
+}
+
+func (s *Memory) ArchiveSession(name string, sessID string, nasIP string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sess, ok := s.sessions[memKey(name, sessID, nasIP)]
+	if !ok {
+		return errors.Wrapf(model.ErrArchiveSession, "sess=%s user=%s", sessID, name)
+	}
+	s.log = append(s.log, SessionLog{Session: sess.Session})
+	return nil
+}
+
+func (s *Memory) ClearNASSessions(nasIP string, cause uint32, before int64) (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var count int64
+	for key, sess := range s.sessions {
+		if sess.NasIP == nasIP && sess.added < before {
+			s.log = append(s.log, SessionLog{Session: sess.Session, TerminateCause: cause})
+			delete(s.sessions, key)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Memory) SelectStaleSessions(before int64) ([]model.Session, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var list []model.Session
+	for _, sess := range s.sessions {
+		if sess.updated < before {
+			list = append(list, sess.Session)
+		}
+	}
+	return list, nil
+}
+
+func (s *Memory) ReapSession(name string, sessID string, nasIP string, cause uint32, before int64) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := memKey(name, sessID, nasIP)
+	sess, ok := s.sessions[key]
+	if !ok || sess.updated >= before {
+		// Updated or reaped meanwhile
+		return false, nil
+	}
+	s.log = append(s.log, SessionLog{Session: sess.Session, TerminateCause: cause})
+	delete(s.sessions, key)
+	return true, nil
+}
+
+func (s *Memory) InsertAcct(name string, date string, rx int64, tx int64, rxPackets int64, txPackets int64, hostname string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, acct := range s.acct {
+		if acct.User == name && acct.Date == date && acct.Hostname == hostname {
+			// Primary key
+			return errors.Wrapf(sync.ErrInsertAcct, "user=%s", name)
+		}
+	}
+	s.acct = append(s.acct, Acct{
+		User:       name,
+		Date:       date,
+		Hostname:   hostname,
+		BytesIn:    rx,
+		BytesOut:   tx,
+		PacketsIn:  rxPackets,
+		PacketsOut: txPackets,
+	})
+	return nil
+}
+
+// Clamped at zero, users without (or with an empty) block are
+// not changed like with MySQL
+func (s *Memory) UpdateUsage(name string, remain int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	user, ok := s.users[name]
+	if !ok || user.BlockRemain == nil || *user.BlockRemain == 0 {
+		return errors.Wrapf(sync.ErrUpdateUsage, "user=%s", name)
+	}
+	block := *user.BlockRemain - remain
+	if block < 0 {
+		block = 0
+	}
+	user.BlockRemain = &block
+	s.users[name] = user
+	return nil
+}
+
+func (s *Memory) SelectRemain(name string) (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	user, ok := s.users[name]
+	if !ok {
+		return 0, model.ErrNoRows
+	}
+	if user.BlockRemain == nil {
+		return 0, nil
+	}
+	return *user.BlockRemain, nil
+}
+
+func (s *Memory) SelectSessions(name string) ([]model.Session, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var list []model.Session
+	for _, sess := range s.sessions {
+		if sess.User == name {
+			list = append(list, sess.Session)
+		}
+	}
+	return list, nil
+}
+
+func (s *Memory) InsertQuotaAction(name string, sessID string, nasIP string, action string, errMsg string, hostname string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.quota = append(s.quota, QuotaAction{
+		User:      name,
+		SessionID: sessID,
+		NasIP:     nasIP,
+		Action:    action,
+		Error:     errMsg,
+		Hostname:  hostname,
+	})
+	return nil
+}
+
+func (s *Memory) AddChallenge(state string, name string, added int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.states[state] = memChallenge{user: name, added: added}
+	return nil
+}
+
+func (s *Memory) TakeChallenge(state string) (string, int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	c, ok := s.states[state]
+	if !ok {
+		return "", 0, model.ErrNoRows
+	}
+	delete(s.states, state)
+	return c.user, c.added, nil
+}
+
+func (s *Memory) ExpireChallenges(before int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for state, c := range s.states {
+		if c.added < before {
+			delete(s.states, state)
+		}
+	}
+	return nil
+}
+
+func (s *Memory) UseTOTPCounter(name string, counter uint64) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if last, ok := s.counters[name]; ok && counter <= last {
+		return false, nil
+	}
+	s.counters[name] = counter
+	return true, nil
+}
@@ -0,0 +1,30 @@
+INSERT INTO session_log (
+  assigned_ip,
+  bytes_in,
+  bytes_out,
+  client_ip,
+  nas_ip,
+  packets_in,
+  packets_out,
+  session_id,
+  session_time,
+  "user",
+  time_added,
+  terminate_cause
+  )
+SELECT
+  assigned_ip,
+  bytes_in,
+  bytes_out,
+  client_ip,
+  nas_ip,
+  packets_in,
+  packets_out,
+  session_id,
+  session_time,
+  "user",
+  time_added,
+  CAST($1 AS integer)
+FROM session
+WHERE nas_ip = $2
+  AND time_added < $3
\ No newline at end of file
@@ -0,0 +1,29 @@
+INSERT INTO session_log (
+  assigned_ip,
+  bytes_in,
+  bytes_out,
+  client_ip,
+  nas_ip,
+  packets_in,
+  packets_out,
+  session_id,
+  session_time,
+  "user",
+  time_added
+  )
+SELECT
+  assigned_ip,
+  bytes_in,
+  bytes_out,
+  client_ip,
+  nas_ip,
+  packets_in,
+  packets_out,
+  session_id,
+  session_time,
+  "user",
+  time_added
+FROM session
+WHERE "user" = $1
+  AND session_id = $2
+  AND nas_ip = $3
\ No newline at end of file
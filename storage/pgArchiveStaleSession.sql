@@ -0,0 +1,33 @@
+INSERT INTO session_log (
+  assigned_ip,
+  bytes_in,
+  bytes_out,
+  client_ip,
+  nas_ip,
+  packets_in,
+  packets_out,
+  session_id,
+  session_time,
+  "user",
+  time_added,
+  terminate_cause
+  )
+SELECT
+  assigned_ip,
+  bytes_in,
+  bytes_out,
+  client_ip,
+  nas_ip,
+  packets_in,
+  packets_out,
+  session_id,
+  session_time,
+  "user",
+  time_added,
+  CAST($1 AS integer)
+FROM session
+WHERE "user" = $2
+  AND session_id = $3
+  AND nas_ip = $4
+  AND time_updated < $5
+  AND time_added < $6
\ No newline at end of file
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgArchiveStaleSession = "INSERT INTO session_log (\n  assigned_ip,\n  bytes_in,\n  bytes_out,\n  client_ip,\n  nas_ip,\n  packets_in,\n  packets_out,\n  session_id,\n  session_time,\n  \"user\",\n  time_added,\n  terminate_cause\n  )\nSELECT\n  assigned_ip,\n  bytes_in,\n  bytes_out,\n  client_ip,\n  nas_ip,\n  packets_in,\n  packets_out,\n  session_id,\n  session_time,\n  \"user\",\n  time_added,\n  CAST($1 AS integer)\nFROM session\nWHERE \"user\" = $2\n  AND session_id = $3\n  AND nas_ip = $4\n  AND time_updated < $5\n  AND time_added < $6"
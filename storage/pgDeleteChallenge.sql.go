@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgDeleteChallenge = "DELETE FROM totp_challenge\nWHERE state = $1"
@@ -0,0 +1,3 @@
+DELETE FROM session
+WHERE nas_ip = $1
+  AND time_added < $2
\ No newline at end of file
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgDeleteNASSessions = "DELETE FROM session\nWHERE nas_ip = $1\n  AND time_added < $2"
@@ -0,0 +1,6 @@
+DELETE FROM session
+WHERE "user" = $1
+  AND session_id = $2
+  AND nas_ip = $3
+  AND time_updated < $4
+  AND time_added < $5
\ No newline at end of file
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgDeleteStaleSession = "DELETE FROM session\nWHERE \"user\" = $1\n  AND session_id = $2\n  AND nas_ip = $3\n  AND time_updated < $4\n  AND time_added < $5"
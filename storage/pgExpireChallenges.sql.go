@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgExpireChallenges = "DELETE FROM totp_challenge\nWHERE time_added < $1"
@@ -0,0 +1,9 @@
+INSERT INTO accounting (
+    "user",
+    date,
+    bytes_in,
+    bytes_out,
+    packets_in,
+    packets_out,
+    hostname
+) VALUES ($1, $2, $3, $4, $5, $6, $7)
\ No newline at end of file
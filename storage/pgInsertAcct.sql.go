@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgInsertAcct = "INSERT INTO accounting (\n    \"user\",\n    date,\n    bytes_in,\n    bytes_out,\n    packets_in,\n    packets_out,\n    hostname\n) VALUES ($1, $2, $3, $4, $5, $6, $7)"
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgInsertChallenge = "INSERT INTO totp_challenge (state, \"user\", time_added)\nVALUES ($1, $2, $3)"
@@ -0,0 +1,4 @@
+INSERT INTO quota_action
+  ("user", session_id, nas_ip, action, error, hostname, time_added)
+VALUES
+  ($1, $2, $3, $4, $5, $6, $7)
\ No newline at end of file
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgInsertQuotaAction = "INSERT INTO quota_action\n  (\"user\", session_id, nas_ip, action, error, hostname, time_added)\nVALUES\n  ($1, $2, $3, $4, $5, $6, $7)"
@@ -0,0 +1,14 @@
+INSERT INTO session (
+  session_id,
+  "user",
+  time_added,
+  nas_ip,
+  assigned_ip,
+  client_ip,
+  bytes_in,
+  bytes_out,
+  packets_in,
+  packets_out,
+  session_time,
+  time_updated
+ ) VALUES ($1, $2, $3, $4, $5, $6, 0, 0, 0, 0, 0, $7)
\ No newline at end of file
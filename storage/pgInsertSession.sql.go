@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgInsertSession = "INSERT INTO session (\n  session_id,\n  \"user\",\n  time_added,\n  nas_ip,\n  assigned_ip,\n  client_ip,\n  bytes_in,\n  bytes_out,\n  packets_in,\n  packets_out,\n  session_time,\n  time_updated\n ) VALUES ($1, $2, $3, $4, $5, $6, 0, 0, 0, 0, 0, $7)"
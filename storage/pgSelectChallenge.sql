@@ -0,0 +1,4 @@
+SELECT "user",
+       time_added
+FROM totp_challenge
+WHERE state = $1
\ No newline at end of file
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgSelectChallenge = "SELECT \"user\",\n       time_added\nFROM totp_challenge\nWHERE state = $1"
@@ -0,0 +1,4 @@
+SELECT TRUE
+FROM "user"
+JOIN product ON "user".product_id = product.id
+WHERE "user" = $1
\ No newline at end of file
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgSelectLimits = "SELECT TRUE\nFROM \"user\"\nJOIN product ON \"user\".product_id = product.id\nWHERE \"user\" = $1"
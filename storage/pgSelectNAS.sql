@@ -0,0 +1,6 @@
+SELECT name,
+       cidr,
+       secret,
+       type,
+       require_ma
+FROM nas
\ No newline at end of file
@@ -0,0 +1,3 @@
+SELECT COUNT(*)
+FROM session
+WHERE "user" = $1
\ No newline at end of file
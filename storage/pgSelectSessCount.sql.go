@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgSelectSessCount = "SELECT COUNT(*)\nFROM session\nWHERE \"user\" = $1"
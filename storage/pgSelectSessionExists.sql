@@ -0,0 +1,5 @@
+SELECT TRUE
+FROM session
+WHERE "user" = $1
+  AND session_id = $2
+  AND nas_ip = $3
\ No newline at end of file
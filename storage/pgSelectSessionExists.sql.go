@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgSelectSessionExists = "SELECT TRUE\nFROM session\nWHERE \"user\" = $1\n  AND session_id = $2\n  AND nas_ip = $3"
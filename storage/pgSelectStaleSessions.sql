@@ -0,0 +1,6 @@
+SELECT session_id,
+       "user",
+       nas_ip
+FROM session
+WHERE time_updated < $1
+  AND time_added < $2
\ No newline at end of file
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgSelectStaleSessions = "SELECT session_id,\n       \"user\",\n       nas_ip\nFROM session\nWHERE time_updated < $1\n  AND time_added < $2"
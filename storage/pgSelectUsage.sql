@@ -0,0 +1,3 @@
+SELECT COALESCE(block_remaining, 0)
+FROM "user"
+WHERE "user" = $1
\ No newline at end of file
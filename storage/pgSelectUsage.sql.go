@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgSelectUsage = "SELECT COALESCE(block_remaining, 0)\nFROM \"user\"\nWHERE \"user\" = $1"
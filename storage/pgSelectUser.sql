@@ -0,0 +1,14 @@
+SELECT pass,
+       block_remaining,
+       TO_CHAR(active_until, 'YYYY-MM-DD'),
+       TRUE,
+       simultaneous_use,
+       dedicated_ip,
+       ratelimit_up || ratelimit_unit || '/' || ratelimit_down || ratelimit_unit,
+       dns.one, dns.two,
+       totp_secret,
+       interim_interval, session_timeout, idle_timeout
+FROM      "user"
+JOIN      product ON "user".product_id = product.id
+LEFT JOIN dns     ON "user".dns_id     = dns.id
+WHERE "user" = $1
\ No newline at end of file
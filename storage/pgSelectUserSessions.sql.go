@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgSelectUserSessions = "SELECT session_id,\n       \"user\",\n       nas_ip,\n       bytes_in,\n       bytes_out,\n       packets_in,\n       packets_out,\n       session_time,\n       assigned_ip,\n       client_ip\nFROM session\nWHERE \"user\" = $1"
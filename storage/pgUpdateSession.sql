@@ -0,0 +1,11 @@
+UPDATE session SET
+  bytes_in     = $1,
+  bytes_out    = $2,
+  packets_in   = $3,
+  packets_out  = $4,
+  session_time = $5,
+  time_updated = $6
+WHERE "user" = $7
+  AND session_id = $8
+  AND nas_ip = $9
+  AND session_time = $10
\ No newline at end of file
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgUpdateSession = "UPDATE session SET\n  bytes_in     = $1,\n  bytes_out    = $2,\n  packets_in   = $3,\n  packets_out  = $4,\n  session_time = $5,\n  time_updated = $6\nWHERE \"user\" = $7\n  AND session_id = $8\n  AND nas_ip = $9\n  AND session_time = $10"
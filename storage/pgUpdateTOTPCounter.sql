@@ -0,0 +1,5 @@
+-- Only forward so a counter is accepted once, also across nodes
+UPDATE "user" SET
+  totp_counter = $1
+WHERE "user" = $2
+  AND (totp_counter IS NULL OR totp_counter < $3)
\ No newline at end of file
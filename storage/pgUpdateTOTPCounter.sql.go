@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgUpdateTOTPCounter = "-- Only forward so a counter is accepted once, also across nodes\nUPDATE \"user\" SET\n  totp_counter = $1\nWHERE \"user\" = $2\n  AND (totp_counter IS NULL OR totp_counter < $3)"
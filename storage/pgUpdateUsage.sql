@@ -0,0 +1,5 @@
+-- block_remaining > 0 so only changed rows count, like MySQL
+UPDATE "user" SET
+  block_remaining = CASE WHEN block_remaining < $1 THEN 0 ELSE block_remaining - $2 END
+WHERE "user" = $3
+  AND block_remaining > 0
\ No newline at end of file
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const pgUpdateUsage = "-- block_remaining > 0 so only changed rows count, like MySQL\nUPDATE \"user\" SET\n  block_remaining = CASE WHEN block_remaining < $1 THEN 0 ELSE block_remaining - $2 END\nWHERE \"user\" = $3\n  AND block_remaining > 0"
@@ -0,0 +1,81 @@
+package storage
+
+// Same queries as mysql.go, with $n placeholders and quoted "user"
+//go:generate embd -n pgArchiveSession      pgArchiveSession.sql
+//go:generate embd -n pgDeleteSession       pgDeleteSession.sql
+//go:generate embd -n pgInsertAcct          pgInsertAcct.sql
+//go:generate embd -n pgInsertSession       pgInsertSession.sql
+//go:generate embd -n pgSelectLimits        pgSelectLimits.sql
+//go:generate embd -n pgSelectSessCount     pgSelectSessCount.sql
+//go:generate embd -n pgSelectSessionExists pgSelectSessionExists.sql
+//go:generate embd -n pgSelectSession       pgSelectSession.sql
+//go:generate embd -n pgSelectUser          pgSelectUser.sql
+//go:generate embd -n pgUpdateSession       pgUpdateSession.sql
+//go:generate embd -n pgUpdateUsage         pgUpdateUsage.sql
+//go:generate embd -n pgSelectUsage         pgSelectUsage.sql
+//go:generate embd -n pgSelectNAS           pgSelectNAS.sql
+//go:generate embd -n pgSelectUserSessions  pgSelectUserSessions.sql
+//go:generate embd -n pgInsertQuotaAction   pgInsertQuotaAction.sql
+//go:generate embd -n pgArchiveNASSessions  pgArchiveNASSessions.sql
+//go:generate embd -n pgDeleteNASSessions   pgDeleteNASSessions.sql
+//go:generate embd -n pgSelectStaleSessions pgSelectStaleSessions.sql
+//go:generate embd -n pgArchiveStaleSession pgArchiveStaleSession.sql
+//go:generate embd -n pgDeleteStaleSession  pgDeleteStaleSession.sql
+//go:generate embd -n pgInsertChallenge     pgInsertChallenge.sql
+//go:generate embd -n pgSelectChallenge     pgSelectChallenge.sql
+//go:generate embd -n pgDeleteChallenge     pgDeleteChallenge.sql
+//go:generate embd -n pgExpireChallenges    pgExpireChallenges.sql
+//go:generate embd -n pgUpdateTOTPCounter   pgUpdateTOTPCounter.sql
+
+import (
+	"database/sql"
+
+	_ "github.com/lib/pq"
+)
+
+var pgQueries = queries{
+	archiveSession:      pgArchiveSession,
+	deleteSession:       pgDeleteSession,
+	insertAcct:          pgInsertAcct,
+	insertSession:       pgInsertSession,
+	selectLimits:        pgSelectLimits,
+	selectSessCount:     pgSelectSessCount,
+	selectSessionExists: pgSelectSessionExists,
+	selectSession:       pgSelectSession,
+	selectUser:          pgSelectUser,
+	updateSession:       pgUpdateSession,
+	updateUsage:         pgUpdateUsage,
+	selectUsage:         pgSelectUsage,
+	selectNAS:           pgSelectNAS,
+	selectUserSessions:  pgSelectUserSessions,
+	insertQuotaAction:   pgInsertQuotaAction,
+	archiveNASSessions:  pgArchiveNASSessions,
+	deleteNASSessions:   pgDeleteNASSessions,
+	selectStaleSessions: pgSelectStaleSessions,
+	archiveStaleSession: pgArchiveStaleSession,
+	deleteStaleSession:  pgDeleteStaleSession,
+	insertChallenge:     pgInsertChallenge,
+	selectChallenge:     pgSelectChallenge,
+	deleteChallenge:     pgDeleteChallenge,
+	expireChallenges:    pgExpireChallenges,
+	updateTOTPCounter:   pgUpdateTOTPCounter,
+}
+
+type Postgres struct {
+	SQL
+}
+
+func NewPostgres(dsn string) (*Postgres, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Postgres{SQL{DB: db, q: &pgQueries}}, nil
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Database of RADIUSD_PG_DSN recreated from db/postgres.sql with the
+// fixtures of test/vpnxs_radius.sql, all its tables are dropped!
+func testPostgres(t *testing.T) (*Postgres, func()) {
+	dsn := os.Getenv("RADIUSD_PG_DSN")
+	if dsn == "" {
+		t.Skip("RADIUSD_PG_DSN not set")
+	}
+	schema, err := ioutil.ReadFile("../db/postgres.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewPostgres(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.Exec(string(schema)); err != nil {
+		s.DB.Close()
+		t.Fatal(err)
+	}
+	_, err = s.DB.Exec(`
+INSERT INTO product (product, simultaneous_use, ratelimit_up, ratelimit_down, ratelimit_unit, interim_interval)
+  VALUES ('derp', 1, 10, 20, 'M', 300);
+INSERT INTO "user" ("user", pass, block_remaining, active_until, product_id, time_added)
+  VALUES ('herp', 'derp', 100, '2030-01-01', 1, 0);
+INSERT INTO nas (name, cidr, secret, require_ma) VALUES ('local', '127.0.0.1/32', 'secret', true);`)
+	if err != nil {
+		s.DB.Close()
+		t.Fatal(err)
+	}
+	return s, func() {
+		s.DB.Close()
+	}
+}
@@ -0,0 +1,4 @@
+SELECT user,
+       time_added
+FROM totp_challenge
+WHERE state = ?
\ No newline at end of file
@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const selectNAS = "SELECT name,\n       cidr,\n       secret,\n       type,\n       require_ma\nFROM nas"
@@ -0,0 +1,6 @@
+SELECT session_id,
+       user,
+       nas_ip
+FROM session
+WHERE time_updated < ?
+  AND time_added < ?
\ No newline at end of file
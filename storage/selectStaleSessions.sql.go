@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const selectStaleSessions = "SELECT session_id,\n       user,\n       nas_ip\nFROM session\nWHERE time_updated < ?\n  AND time_added < ?"
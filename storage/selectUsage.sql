@@ -1,3 +1,3 @@
-SELECT block_remaining
+SELECT IFNULL(block_remaining, 0)
 FROM user
 WHERE user = ?
\ No newline at end of file
@@ -1,4 +1,4 @@
 package storage
 
 //generated by embd
-const selectUsage = "SELECT block_remaining\nFROM user\nWHERE user = ?"
+const selectUsage = "SELECT IFNULL(block_remaining, 0)\nFROM user\nWHERE user = ?"
@@ -5,7 +5,9 @@ SELECT pass,
        simultaneous_use,
        dedicated_ip,
        CONCAT(ratelimit_up, ratelimit_unit, '/', ratelimit_down, ratelimit_unit),
-       dns.one, dns.two
+       dns.one, dns.two,
+       totp_secret,
+       interim_interval, session_timeout, idle_timeout
 FROM      user
 JOIN      product ON user.product_id = product.id
 LEFT JOIN dns     ON user.dns_id     = dns.id
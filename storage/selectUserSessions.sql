@@ -0,0 +1,12 @@
+SELECT session_id,
+       user,
+       nas_ip,
+       bytes_in,
+       bytes_out,
+       packets_in,
+       packets_out,
+       session_time,
+       assigned_ip,
+       client_ip
+FROM session
+WHERE user = ?
\ No newline at end of file
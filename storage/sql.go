@@ -0,0 +1,399 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/sync"
+	"github.com/pkg/errors"
+)
+
+// Queries in the dialect of the driver
+type queries struct {
+	archiveSession      string
+	deleteSession       string
+	insertAcct          string
+	insertSession       string
+	selectLimits        string
+	selectSessCount     string
+	selectSessionExists string
+	selectSession       string
+	selectUser          string
+	updateSession       string
+	updateUsage         string
+	selectUsage         string
+	selectNAS           string
+	selectUserSessions  string
+	insertQuotaAction   string
+	archiveNASSessions  string
+	deleteNASSessions   string
+	selectStaleSessions string
+	archiveStaleSession string
+	deleteStaleSession  string
+	insertChallenge     string
+	selectChallenge     string
+	deleteChallenge     string
+	expireChallenges    string
+	updateTOTPCounter   string
+}
+
+// model.Storage, sync.Storage and reaper.Storage on database/sql
+type SQL struct {
+	DB *sql.DB
+	q  *queries
+}
+
+func (s *SQL) GetUser(name string) (user model.User, err error) {
+	err = s.DB.QueryRow(s.q.selectUser, name).Scan(
+		&user.Pass,
+		&user.BlockRemain,
+		&user.ActiveUntil,
+		&user.Ok,
+		&user.SimultaneousUse,
+		&user.DedicatedIP,
+		&user.Ratelimit,
+		&user.DnsOne,
+		&user.DnsTwo,
+		&user.TOTPSecret,
+		&user.InterimInterval,
+		&user.SessionTimeout,
+		&user.IdleTimeout,
+	)
+	if err == sql.ErrNoRows {
+		return user, nil
+	}
+	return user, err
+}
+
+func (s *SQL) CountSessions(name string) (count int, err error) {
+	err = s.DB.QueryRow(s.q.selectSessCount, name).Scan(&count)
+	return count, err
+}
+
+func (s *SQL) GetLimits(user string) (limits model.UserLimits, err error) {
+	err = s.DB.QueryRow(s.q.selectLimits, user).Scan(&limits.Exists)
+	return limits, err
+}
+
+func (s *SQL) GetNASList() (list []model.NAS, err error) {
+	rows, err := s.DB.Query(s.q.selectNAS)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var nas model.NAS
+		if err := rows.Scan(&nas.Name, &nas.CIDR, &nas.Secret, &nas.Type, &nas.RequireMessageAuthenticator); err != nil {
+			return nil, err
+		}
+		list = append(list, nas)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQL) IsSessionExists(name string, sessID string, nasIP string) (exists bool, err error) {
+	err = s.DB.QueryRow(
+		s.q.selectSessionExists,
+		name, sessID, nasIP,
+	).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	return exists, err
+}
+
+func (s *SQL) GetSession(name string, sessID string, nasIP string) (sess model.Session, err error) {
+	err = s.DB.QueryRow(
+		s.q.selectSession,
+		name, sessID, nasIP,
+	).Scan(
+		&sess.SessionID,
+		&sess.User,
+		&sess.NasIP,
+		&sess.BytesIn,
+		&sess.BytesOut,
+		&sess.PacketsIn,
+		&sess.PacketsOut,
+		&sess.SessionTime,
+		&sess.AssignedIP,
+		&sess.ClientIP,
+	)
+	if err == sql.ErrNoRows {
+		return sess, model.ErrNoRows
+	}
+	return sess, err
+}
+
+func (s *SQL) CreateSession(
+	name string,
+	sessID string,
+	nasIP string,
+	assignedIP string,
+	clientIP string,
+) error {
+	now := time.Now().Unix()
+	res, err := s.DB.Exec(
+		s.q.insertSession,
+		sessID, name, now, nasIP, assignedIP, clientIP, now,
+	)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(affectCheck(res, 1, model.ErrCreateSession), "sess=%s user=%s", sessID, name)
+}
+
+func (s *SQL) UpdateSession(
+	name string,
+	sessID string,
+	nasIP string,
+	rx int64,
+	tx int64,
+	rxPackets int,
+	txPackets int,
+	duration int,
+	prevDuration int,
+) error {
+	res, err := s.DB.Exec(
+		s.q.updateSession,
+		rx, tx, rxPackets, txPackets, duration, time.Now().Unix(), name, sessID, nasIP, prevDuration,
+	)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(affectCheck(res, 1, model.ErrUpdateSession), "sess=%s user=%s", sessID, name)
+}
+
+func (s *SQL) FinishSession(name string, sessID string, nasIP string) error {
+	res, err := s.DB.Exec(
+		s.q.deleteSession,
+		name, sessID, nasIP,
+	)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(affectCheck(res, 1, model.ErrFinishSession), "sess=%s user=%s", sessID, name)
+}
+
+func (s *SQL) ArchiveSession(name string, sessID string, nasIP string) error {
+	res, err := s.DB.Exec(
+		s.q.archiveSession,
+		name, sessID, nasIP,
+	)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(affectCheck(res, 1, model.ErrArchiveSession), "sess=%s user=%s", sessID, name)
+}
+
+// Archive and delete in one transaction, a session added by a
+// concurrent Start is never deleted without being archived
+func (s *SQL) ClearNASSessions(nasIP string, cause uint32, before int64) (int64, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(s.q.archiveNASSessions, cause, nasIP, before); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(s.q.deleteNASSessions, nasIP, before)
+	if err != nil {
+		return 0, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Stale on both time_updated and time_added, sessions started by a
+// node without time_updated (rolling upgrade) keep it at 0
+func (s *SQL) SelectStaleSessions(before int64) (list []model.Session, err error) {
+	rows, err := s.DB.Query(s.q.selectStaleSessions, before, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sess model.Session
+		if err := rows.Scan(&sess.SessionID, &sess.User, &sess.NasIP); err != nil {
+			return nil, err
+		}
+		list = append(list, sess)
+	}
+	return list, rows.Err()
+}
+
+// Archive+delete in one transaction, the row must still be stale.
+// Another node reaping the same session either deadlocks with us on
+// the row lock or (Galera) fails certification on commit, the loser
+// rolls back its archived copy so session_log has the session once.
+func (s *SQL) ReapSession(name string, sessID string, nasIP string, cause uint32, before int64) (bool, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(s.q.archiveStaleSession, cause, name, sessID, nasIP, before, before)
+	if err != nil {
+		return false, err
+	}
+	if affect, err := res.RowsAffected(); err != nil || affect != 1 {
+		// Updated or reaped meanwhile
+		return false, err
+	}
+	res, err = tx.Exec(s.q.deleteStaleSession, name, sessID, nasIP, before, before)
+	if err != nil {
+		return false, err
+	}
+	if err := affectCheck(res, 1, model.ErrFinishSession); err != nil {
+		return false, errors.Wrapf(err, "sess=%s user=%s", sessID, name)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *SQL) InsertAcct(name string, date string, rx int64, tx int64, rxPackets int64, txPackets int64, hostname string) error {
+	res, err := s.DB.Exec(
+		s.q.insertAcct,
+		name, date, rx, tx, rxPackets, txPackets, hostname,
+	)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(affectCheck(res, 1, sync.ErrInsertAcct), "user=%s", name)
+}
+
+func (s *SQL) UpdateUsage(name string, remain int64) error {
+	res, err := s.DB.Exec(
+		s.q.updateUsage,
+		remain, remain, name,
+	)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(affectCheck(res, 1, sync.ErrUpdateUsage), "user=%s", name)
+}
+
+func (s *SQL) SelectRemain(name string) (remain int64, err error) {
+	err = s.DB.QueryRow(s.q.selectUsage, name).Scan(&remain)
+	return remain, err
+}
+
+func (s *SQL) SelectSessions(name string) (list []model.Session, err error) {
+	rows, err := s.DB.Query(s.q.selectUserSessions, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sess model.Session
+		if err := rows.Scan(
+			&sess.SessionID,
+			&sess.User,
+			&sess.NasIP,
+			&sess.BytesIn,
+			&sess.BytesOut,
+			&sess.PacketsIn,
+			&sess.PacketsOut,
+			&sess.SessionTime,
+			&sess.AssignedIP,
+			&sess.ClientIP,
+		); err != nil {
+			return nil, err
+		}
+		list = append(list, sess)
+	}
+	return list, rows.Err()
+}
+
+func (s *SQL) InsertQuotaAction(name string, sessID string, nasIP string, action string, errMsg string, hostname string) error {
+	res, err := s.DB.Exec(
+		s.q.insertQuotaAction,
+		name, sessID, nasIP, action, errMsg, hostname, time.Now().Unix(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(affectCheck(res, 1, sync.ErrInsertQuotaAction), "sess=%s user=%s", sessID, name)
+}
+
+func (s *SQL) AddChallenge(state string, name string, added int64) error {
+	_, err := s.DB.Exec(s.q.insertChallenge, state, name, added)
+	return err
+}
+
+// Select+delete in one transaction, of nodes taking the same State
+// only one deletes it, the others get ErrNoRows
+func (s *SQL) TakeChallenge(state string) (name string, added int64, err error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return "", 0, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(s.q.selectChallenge, state).Scan(&name, &added)
+	if err == sql.ErrNoRows {
+		return "", 0, model.ErrNoRows
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	res, err := tx.Exec(s.q.deleteChallenge, state)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := affectCheck(res, 1, model.ErrNoRows); err != nil {
+		return "", 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", 0, err
+	}
+	return name, added, nil
+}
+
+func (s *SQL) ExpireChallenges(before int64) error {
+	_, err := s.DB.Exec(s.q.expireChallenges, before)
+	return err
+}
+
+// False if counter (or a later one) was used before
+func (s *SQL) UseTOTPCounter(name string, counter uint64) (bool, error) {
+	res, err := s.DB.Exec(s.q.updateTOTPCounter, int64(counter), name, int64(counter))
+	if err != nil {
+		return false, err
+	}
+	affect, err := res.RowsAffected()
+	return affect == 1, err
+}
+
+func affectCheck(res sql.Result, expect int64, unexpected error) error {
+	affect, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affect != expect {
+		return unexpected
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/sync"
+	"github.com/pkg/errors"
+)
+
+// Run fn against every backend with the fixtures of
+// test/vpnxs_radius.sql, Postgres only with RADIUSD_PG_DSN
+func testDBs(t *testing.T, fn func(t *testing.T, s *SQL)) {
+	t.Run("sqlite", func(t *testing.T) {
+		s, done := testSQLite(t)
+		defer done()
+		fn(t, &s.SQL)
+	})
+	t.Run("postgres", func(t *testing.T) {
+		s, done := testPostgres(t)
+		defer done()
+		fn(t, &s.SQL)
+	})
+}
+
+func TestSQLUser(t *testing.T) {
+	testDBs(t, testSQLUser)
+}
+
+func testSQLUser(t *testing.T, s *SQL) {
+	user, err := s.GetUser("herp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.Ok || user.Pass != "derp" || user.SimultaneousUse != 1 {
+		t.Fatalf("Unexpected user %+v", user)
+	}
+	if user.Ratelimit == nil || *user.Ratelimit != "10M/20M" {
+		t.Fatalf("Ratelimit wrong, found=%v", user.Ratelimit)
+	}
+	if user.ActiveUntil == nil || *user.ActiveUntil != "2030-01-01" {
+		t.Fatalf("ActiveUntil wrong, found=%v", user.ActiveUntil)
+	}
+	if user.InterimInterval == nil || *user.InterimInterval != 300 || user.SessionTimeout != nil {
+		t.Fatal("Product timeouts wrong")
+	}
+
+	user, err = s.GetUser("unknown")
+	if err != nil || user.Ok {
+		t.Fatalf("Unknown user must not be Ok, e=%v", err)
+	}
+
+	list, err := s.GetNASList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !list[0].RequireMessageAuthenticator {
+		t.Fatalf("Unexpected NAS list %+v", list)
+	}
+}
+
+func TestSQLSession(t *testing.T) {
+	testDBs(t, testSQLSession)
+}
+
+func testSQLSession(t *testing.T, s *SQL) {
+	if err := s.CreateSession("herp", "sess1", "127.0.0.1", "10.0.0.2", "1.2.3.4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateSession("herp", "sess1", "127.0.0.1", 1<<33, 10, 1, 2, 60, 0); err != nil {
+		t.Fatal(err)
+	}
+	// Stale prevDuration, someone else updated first
+	err := s.UpdateSession("herp", "sess1", "127.0.0.1", 1, 1, 1, 1, 61, 0)
+	if errors.Cause(err) != model.ErrUpdateSession {
+		t.Fatalf("Expected ErrUpdateSession, found=%v", err)
+	}
+
+	sess, err := s.GetSession("herp", "sess1", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.BytesIn != 1<<33 || sess.SessionTime != 60 {
+		t.Fatalf("Unexpected session %+v", sess)
+	}
+	if count, err := s.CountSessions("herp"); err != nil || count != 1 {
+		t.Fatalf("Expected 1 session, found=%d e=%v", count, err)
+	}
+
+	if err := s.ArchiveSession("herp", "sess1", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.FinishSession("herp", "sess1", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetSession("herp", "sess1", "127.0.0.1"); err != model.ErrNoRows {
+		t.Fatalf("Expected ErrNoRows, found=%v", err)
+	}
+}
+
+func TestSQLReap(t *testing.T) {
+	testDBs(t, testSQLReap)
+}
+
+func testSQLReap(t *testing.T, s *SQL) {
+	if err := s.CreateSession("herp", "sess1", "127.0.0.1", "10.0.0.2", "1.2.3.4"); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().Unix() + 1
+	list, err := s.SelectStaleSessions(before)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("Expected 1 stale session, found=%d e=%v", len(list), err)
+	}
+	ok, err := s.ReapSession("herp", "sess1", "127.0.0.1", radius.TerminateLostService, before)
+	if err != nil || !ok {
+		t.Fatalf("Reap failed, e=%v", err)
+	}
+	// Already reaped (i.e. by another node)
+	if ok, err := s.ReapSession("herp", "sess1", "127.0.0.1", radius.TerminateLostService, before); err != nil || ok {
+		t.Fatalf("Reaped twice, e=%v", err)
+	}
+
+	var cause int
+	if err := s.DB.QueryRow("SELECT terminate_cause FROM session_log").Scan(&cause); err != nil {
+		t.Fatal(err)
+	}
+	if cause != int(radius.TerminateLostService) {
+		t.Fatalf("terminate_cause wrong, found=%d", cause)
+	}
+
+	// Started by a node without time_updated, only stale by time_added
+	if err := s.CreateSession("herp", "sess2", "127.0.0.1", "10.0.0.2", "1.2.3.4"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DB.Exec("UPDATE session SET time_updated = 0"); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := s.SelectStaleSessions(before - 60); err != nil || len(list) != 0 {
+		t.Fatalf("Expected no stale session, found=%d e=%v", len(list), err)
+	}
+	if ok, err := s.ReapSession("herp", "sess2", "127.0.0.1", radius.TerminateLostService, before-60); err != nil || ok {
+		t.Fatalf("Reaped a new session, e=%v", err)
+	}
+}
+
+func TestSQLUsage(t *testing.T) {
+	testDBs(t, testSQLUsage)
+}
+
+func testSQLUsage(t *testing.T, s *SQL) {
+	if err := s.InsertAcct("herp", "2030-01-01 00:00", 60, 40, 1, 1, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateUsage("herp", 60); err != nil {
+		t.Fatal(err)
+	}
+	// Clamped at zero
+	if err := s.UpdateUsage("herp", 60); err != nil {
+		t.Fatal(err)
+	}
+	if remain, err := s.SelectRemain("herp"); err != nil || remain != 0 {
+		t.Fatalf("Expected 0 remaining, found=%d e=%v", remain, err)
+	}
+	// Nothing left to change
+	if err := s.UpdateUsage("herp", 60); errors.Cause(err) != sync.ErrUpdateUsage {
+		t.Fatalf("Expected ErrUpdateUsage, found=%v", err)
+	}
+}
+
+func TestSQLClearNAS(t *testing.T) {
+	testDBs(t, testSQLClearNAS)
+}
+
+func testSQLClearNAS(t *testing.T, s *SQL) {
+	for _, sessID := range []string{"sess1", "sess2"} {
+		if err := s.CreateSession("herp", sessID, "127.0.0.1", "10.0.0.2", "1.2.3.4"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count, err := s.ClearNASSessions("127.0.0.1", radius.TerminateNASReboot, time.Now().Unix()+1)
+	if err != nil || count != 2 {
+		t.Fatalf("Expected 2 cleared, found=%d e=%v", count, err)
+	}
+	if count, err := s.CountSessions("herp"); err != nil || count != 0 {
+		t.Fatalf("Expected 0 sessions, found=%d e=%v", count, err)
+	}
+	var logged int
+	if err := s.DB.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM session_log WHERE terminate_cause = %d", radius.TerminateNASReboot)).Scan(&logged); err != nil {
+		t.Fatal(err)
+	}
+	if logged != 2 {
+		t.Fatalf("Expected 2 archived, found=%d", logged)
+	}
+}
+
+func TestSQLChallenge(t *testing.T) {
+	testDBs(t, testSQLChallenge)
+}
+
+func testSQLChallenge(t *testing.T, s *SQL) {
+	now := time.Now().Unix()
+	if err := s.AddChallenge("aa", "herp", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddChallenge("bb", "herp", now-100); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ExpireChallenges(now - 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.TakeChallenge("bb"); err != model.ErrNoRows {
+		t.Fatalf("Expected expired challenge gone, e=%v", err)
+	}
+	user, added, err := s.TakeChallenge("aa")
+	if err != nil || user != "herp" || added != now {
+		t.Fatalf("Challenge mismatch user=%s added=%d e=%v", user, added, err)
+	}
+	if _, _, err := s.TakeChallenge("aa"); err != model.ErrNoRows {
+		t.Fatalf("Expected challenge taken once, e=%v", err)
+	}
+
+	for i, c := range []struct {
+		counter uint64
+		ok      bool
+	}{{100, true}, {100, false}, {99, false}, {101, true}} {
+		ok, err := s.UseTOTPCounter("herp", c.counter)
+		if err != nil || ok != c.ok {
+			t.Fatalf("%d: counter=%d expect=%t found=%t e=%v", i, c.counter, c.ok, ok, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+// Same queries as mysql.go, except where SQLite differs
+//go:generate embd -n sqliteSchema      sqliteSchema.sql
+//go:generate embd -n sqliteSelectUser  sqliteSelectUser.sql
+//go:generate embd -n sqliteUpdateUsage sqliteUpdateUsage.sql
+
+import (
+	"database/sql"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var sqliteQueries = queries{
+	archiveSession:      archiveSession,
+	deleteSession:       deleteSession,
+	insertAcct:          insertAcct,
+	insertSession:       insertSession,
+	selectLimits:        selectLimits,
+	selectSessCount:     selectSessCount,
+	selectSessionExists: selectSessionExists,
+	selectSession:       selectSession,
+	selectUser:          sqliteSelectUser,
+	updateSession:       updateSession,
+	updateUsage:         sqliteUpdateUsage,
+	selectUsage:         selectUsage,
+	selectNAS:           selectNAS,
+	selectUserSessions:  selectUserSessions,
+	insertQuotaAction:   insertQuotaAction,
+	archiveNASSessions:  archiveNASSessions,
+	deleteNASSessions:   deleteNASSessions,
+	selectStaleSessions: selectStaleSessions,
+	archiveStaleSession: archiveStaleSession,
+	deleteStaleSession:  deleteStaleSession,
+	insertChallenge:     insertChallenge,
+	selectChallenge:     selectChallenge,
+	deleteChallenge:     deleteChallenge,
+	expireChallenges:    expireChallenges,
+	updateTOTPCounter:   updateTOTPCounter,
+}
+
+type SQLite struct {
+	SQL
+}
+
+// Open database file (created if missing) and create the tables
+func NewSQLite(path string) (*SQLite, error) {
+	dsn := path
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_foreign_keys=1&_busy_timeout=5000"
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// One writer at a time, else concurrent handlers get SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLite{SQL{DB: db, q: &sqliteQueries}}, nil
+}
@@ -0,0 +1,126 @@
+-- Equivalent of db/vpnxs_radius.sql, created on open
+CREATE TABLE IF NOT EXISTS dns (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name varchar(10) NOT NULL UNIQUE,
+  one varchar(50) NOT NULL UNIQUE,
+  two varchar(50) NOT NULL
+);
+
+-- RADIUS clients
+CREATE TABLE IF NOT EXISTS nas (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name varchar(50) NOT NULL UNIQUE,
+  cidr varchar(50) NOT NULL UNIQUE,
+  secret varchar(255) NOT NULL,
+  type varchar(20) NOT NULL DEFAULT 'other',
+  require_ma INTEGER NOT NULL DEFAULT 0 CHECK (require_ma IN (0, 1))
+);
+
+CREATE TABLE IF NOT EXISTS product (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  product varchar(50) NOT NULL UNIQUE,
+  simultaneous_use INTEGER NOT NULL CHECK (simultaneous_use >= 0),
+  ratelimit_up INTEGER DEFAULT NULL CHECK (ratelimit_up >= 0),
+  ratelimit_down INTEGER DEFAULT NULL CHECK (ratelimit_down >= 0),
+  ratelimit_unit varchar(1) DEFAULT NULL CHECK (ratelimit_unit IN ('k', 'M')),
+  interim_interval INTEGER DEFAULT NULL CHECK (interim_interval >= 0),
+  session_timeout INTEGER DEFAULT NULL CHECK (session_timeout >= 0),
+  idle_timeout INTEGER DEFAULT NULL CHECK (idle_timeout >= 0)
+);
+
+CREATE TABLE IF NOT EXISTS user (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user varchar(100) NOT NULL UNIQUE,
+  pass varchar(255) NOT NULL,
+  block_remaining INTEGER DEFAULT NULL CHECK (block_remaining >= 0),
+  active_until varchar(10) DEFAULT NULL, -- YYYY-MM-DD
+  dedicated_ip varchar(50) DEFAULT NULL UNIQUE,
+  product_id INTEGER NOT NULL REFERENCES product (id),
+  dns_id INTEGER DEFAULT NULL REFERENCES dns (id),
+  time_added INTEGER NOT NULL,
+  time_updated INTEGER DEFAULT NULL,
+  totp_secret varchar(64) DEFAULT NULL,
+  totp_counter INTEGER DEFAULT NULL CHECK (totp_counter >= 0)
+);
+CREATE INDEX IF NOT EXISTS fk_user_product ON user (product_id);
+CREATE INDEX IF NOT EXISTS fk_user_dns_1 ON user (dns_id);
+
+-- date is 1min consolidated YYYY-MM-DD HH:MM
+CREATE TABLE IF NOT EXISTS accounting (
+  user varchar(100) NOT NULL REFERENCES user (user),
+  date varchar(16) NOT NULL DEFAULT '',
+  hostname varchar(50) NOT NULL,
+  bytes_in INTEGER NOT NULL CHECK (bytes_in >= 0),
+  bytes_out INTEGER NOT NULL CHECK (bytes_out >= 0),
+  packets_in INTEGER NOT NULL CHECK (packets_in >= 0),
+  packets_out INTEGER NOT NULL CHECK (packets_out >= 0),
+  PRIMARY KEY (user, date, hostname)
+);
+
+CREATE TABLE IF NOT EXISTS dedi_ip (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER DEFAULT NULL UNIQUE,
+  ip varchar(50) NOT NULL UNIQUE,
+  time_added INTEGER NOT NULL,
+  time_reserved INTEGER DEFAULT NULL,
+  time_updated INTEGER NOT NULL
+);
+
+-- Sessions disconnected/throttled on empty block
+CREATE TABLE IF NOT EXISTS quota_action (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user varchar(100) NOT NULL REFERENCES user (user),
+  session_id varchar(20) NOT NULL,
+  nas_ip varchar(50) NOT NULL,
+  action varchar(10) NOT NULL CHECK (action IN ('disconnect', 'coa')),
+  error varchar(255) NOT NULL DEFAULT '',
+  hostname varchar(50) NOT NULL,
+  time_added INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS fk_quota_action_user ON quota_action (user);
+
+-- Active connections
+CREATE TABLE IF NOT EXISTS session (
+  session_id varchar(20) NOT NULL,
+  user varchar(100) NOT NULL REFERENCES user (user),
+  nas_ip varchar(50) NOT NULL,
+  bytes_in INTEGER NOT NULL CHECK (bytes_in >= 0),
+  bytes_out INTEGER NOT NULL CHECK (bytes_out >= 0),
+  packets_in INTEGER NOT NULL CHECK (packets_in >= 0),
+  packets_out INTEGER NOT NULL CHECK (packets_out >= 0),
+  session_time INTEGER NOT NULL CHECK (session_time >= 0),
+  client_ip varchar(50) NOT NULL,
+  assigned_ip varchar(50) NOT NULL,
+  time_added INTEGER NOT NULL,
+  time_updated INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (session_id, user, nas_ip)
+);
+CREATE INDEX IF NOT EXISTS fk_session_user ON session (user);
+CREATE INDEX IF NOT EXISTS idx_session_updated ON session (time_updated);
+
+-- Closed connections
+CREATE TABLE IF NOT EXISTS session_log (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  bytes_in INTEGER NOT NULL CHECK (bytes_in >= 0),
+  bytes_out INTEGER NOT NULL CHECK (bytes_out >= 0),
+  packets_in INTEGER NOT NULL CHECK (packets_in >= 0),
+  packets_out INTEGER NOT NULL CHECK (packets_out >= 0),
+  session_id varchar(20) NOT NULL,
+  session_time INTEGER NOT NULL CHECK (session_time >= 0),
+  user varchar(100) NOT NULL REFERENCES user (user),
+  nas_ip varchar(50) NOT NULL,
+  client_ip varchar(50) NOT NULL,
+  assigned_ip varchar(50) NOT NULL,
+  time_added INTEGER NOT NULL,
+  terminate_cause INTEGER DEFAULT NULL CHECK (terminate_cause >= 0)
+);
+CREATE INDEX IF NOT EXISTS fk_session_log_user ON session_log (user);
+
+-- Pending one-time code prompts, state is the hex RADIUS State
+CREATE TABLE IF NOT EXISTS totp_challenge (
+  state varchar(32) PRIMARY KEY,
+  user varchar(100) NOT NULL REFERENCES user (user),
+  time_added INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS fk_totp_challenge_user ON totp_challenge (user);
+CREATE INDEX IF NOT EXISTS idx_totp_challenge_added ON totp_challenge (time_added);
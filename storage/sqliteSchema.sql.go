@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const sqliteSchema = "-- Equivalent of db/vpnxs_radius.sql, created on open\nCREATE TABLE IF NOT EXISTS dns (\n  id INTEGER PRIMARY KEY AUTOINCREMENT,\n  name varchar(10) NOT NULL UNIQUE,\n  one varchar(50) NOT NULL UNIQUE,\n  two varchar(50) NOT NULL\n);\n\n-- RADIUS clients\nCREATE TABLE IF NOT EXISTS nas (\n  id INTEGER PRIMARY KEY AUTOINCREMENT,\n  name varchar(50) NOT NULL UNIQUE,\n  cidr varchar(50) NOT NULL UNIQUE,\n  secret varchar(255) NOT NULL,\n  type varchar(20) NOT NULL DEFAULT 'other',\n  require_ma INTEGER NOT NULL DEFAULT 0 CHECK (require_ma IN (0, 1))\n);\n\nCREATE TABLE IF NOT EXISTS product (\n  id INTEGER PRIMARY KEY AUTOINCREMENT,\n  product varchar(50) NOT NULL UNIQUE,\n  simultaneous_use INTEGER NOT NULL CHECK (simultaneous_use >= 0),\n  ratelimit_up INTEGER DEFAULT NULL CHECK (ratelimit_up >= 0),\n  ratelimit_down INTEGER DEFAULT NULL CHECK (ratelimit_down >= 0),\n  ratelimit_unit varchar(1) DEFAULT NULL CHECK (ratelimit_unit IN ('k', 'M')),\n  interim_interval INTEGER DEFAULT NULL CHECK (interim_interval >= 0),\n  session_timeout INTEGER DEFAULT NULL CHECK (session_timeout >= 0),\n  idle_timeout INTEGER DEFAULT NULL CHECK (idle_timeout >= 0)\n);\n\nCREATE TABLE IF NOT EXISTS user (\n  id INTEGER PRIMARY KEY AUTOINCREMENT,\n  user varchar(100) NOT NULL UNIQUE,\n  pass varchar(255) NOT NULL,\n  block_remaining INTEGER DEFAULT NULL CHECK (block_remaining >= 0),\n  active_until varchar(10) DEFAULT NULL, -- YYYY-MM-DD\n  dedicated_ip varchar(50) DEFAULT NULL UNIQUE,\n  product_id INTEGER NOT NULL REFERENCES product (id),\n  dns_id INTEGER DEFAULT NULL REFERENCES dns (id),\n  time_added INTEGER NOT NULL,\n  time_updated INTEGER DEFAULT NULL,\n  totp_secret varchar(64) DEFAULT NULL,\n  totp_counter INTEGER DEFAULT NULL CHECK (totp_counter >= 0)\n);\nCREATE INDEX IF NOT EXISTS fk_user_product ON user (product_id);\nCREATE INDEX IF NOT EXISTS fk_user_dns_1 ON user (dns_id);\n\n-- date is 1min consolidated YYYY-MM-DD HH:MM\nCREATE TABLE IF NOT EXISTS accounting (\n  user varchar(100) NOT NULL REFERENCES user (user),\n  date varchar(16) NOT NULL DEFAULT '',\n  hostname varchar(50) NOT NULL,\n  bytes_in INTEGER NOT NULL CHECK (bytes_in >= 0),\n  bytes_out INTEGER NOT NULL CHECK (bytes_out >= 0),\n  packets_in INTEGER NOT NULL CHECK (packets_in >= 0),\n  packets_out INTEGER NOT NULL CHECK (packets_out >= 0),\n  PRIMARY KEY (user, date, hostname)\n);\n\nCREATE TABLE IF NOT EXISTS dedi_ip (\n  id INTEGER PRIMARY KEY AUTOINCREMENT,\n  user_id INTEGER DEFAULT NULL UNIQUE,\n  ip varchar(50) NOT NULL UNIQUE,\n  time_added INTEGER NOT NULL,\n  time_reserved INTEGER DEFAULT NULL,\n  time_updated INTEGER NOT NULL\n);\n\n-- Sessions disconnected/throttled on empty block\nCREATE TABLE IF NOT EXISTS quota_action (\n  id INTEGER PRIMARY KEY AUTOINCREMENT,\n  user varchar(100) NOT NULL REFERENCES user (user),\n  session_id varchar(20) NOT NULL,\n  nas_ip varchar(50) NOT NULL,\n  action varchar(10) NOT NULL CHECK (action IN ('disconnect', 'coa')),\n  error varchar(255) NOT NULL DEFAULT '',\n  hostname varchar(50) NOT NULL,\n  time_added INTEGER NOT NULL\n);\nCREATE INDEX IF NOT EXISTS fk_quota_action_user ON quota_action (user);\n\n-- Active connections\nCREATE TABLE IF NOT EXISTS session (\n  session_id varchar(20) NOT NULL,\n  user varchar(100) NOT NULL REFERENCES user (user),\n  nas_ip varchar(50) NOT NULL,\n  bytes_in INTEGER NOT NULL CHECK (bytes_in >= 0),\n  bytes_out INTEGER NOT NULL CHECK (bytes_out >= 0),\n  packets_in INTEGER NOT NULL CHECK (packets_in >= 0),\n  packets_out INTEGER NOT NULL CHECK (packets_out >= 0),\n  session_time INTEGER NOT NULL CHECK (session_time >= 0),\n  client_ip varchar(50) NOT NULL,\n  assigned_ip varchar(50) NOT NULL,\n  time_added INTEGER NOT NULL,\n  time_updated INTEGER NOT NULL DEFAULT 0,\n  PRIMARY KEY (session_id, user, nas_ip)\n);\nCREATE INDEX IF NOT EXISTS fk_session_user ON session (user);\nCREATE INDEX IF NOT EXISTS idx_session_updated ON session (time_updated);\n\n-- Closed connections\nCREATE TABLE IF NOT EXISTS session_log (\n  id INTEGER PRIMARY KEY AUTOINCREMENT,\n  bytes_in INTEGER NOT NULL CHECK (bytes_in >= 0),\n  bytes_out INTEGER NOT NULL CHECK (bytes_out >= 0),\n  packets_in INTEGER NOT NULL CHECK (packets_in >= 0),\n  packets_out INTEGER NOT NULL CHECK (packets_out >= 0),\n  session_id varchar(20) NOT NULL,\n  session_time INTEGER NOT NULL CHECK (session_time >= 0),\n  user varchar(100) NOT NULL REFERENCES user (user),\n  nas_ip varchar(50) NOT NULL,\n  client_ip varchar(50) NOT NULL,\n  assigned_ip varchar(50) NOT NULL,\n  time_added INTEGER NOT NULL,\n  terminate_cause INTEGER DEFAULT NULL CHECK (terminate_cause >= 0)\n);\nCREATE INDEX IF NOT EXISTS fk_session_log_user ON session_log (user);\n\n-- Pending one-time code prompts, state is the hex RADIUS State\nCREATE TABLE IF NOT EXISTS totp_challenge (\n  state varchar(32) PRIMARY KEY,\n  user varchar(100) NOT NULL REFERENCES user (user),\n  time_added INTEGER NOT NULL\n);\nCREATE INDEX IF NOT EXISTS fk_totp_challenge_user ON totp_challenge (user);\nCREATE INDEX IF NOT EXISTS idx_totp_challenge_added ON totp_challenge (time_added);\n"
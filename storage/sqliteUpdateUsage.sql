@@ -0,0 +1,5 @@
+-- block_remaining > 0 so only changed rows count, like MySQL
+UPDATE user SET
+  block_remaining = CASE WHEN block_remaining < ? THEN 0 ELSE block_remaining - ? END
+WHERE user = ?
+  AND block_remaining > 0
\ No newline at end of file
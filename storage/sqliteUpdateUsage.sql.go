@@ -0,0 +1,4 @@
+package storage
+
+//generated by embd
+const sqliteUpdateUsage = "-- block_remaining > 0 so only changed rows count, like MySQL\nUPDATE user SET\n  block_remaining = CASE WHEN block_remaining < ? THEN 0 ELSE block_remaining - ? END\nWHERE user = ?\n  AND block_remaining > 0"
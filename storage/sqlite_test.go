@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Fresh database with the fixtures of test/vpnxs_radius.sql
+func testSQLite(t *testing.T) (*SQLite, func()) {
+	dir, err := ioutil.TempDir("", "radiusd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSQLite(filepath.Join(dir, "radiusd.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	_, err = s.DB.Exec(`
+INSERT INTO product VALUES (1, 'derp', 1, 10, 20, 'M', 300, NULL, NULL);
+INSERT INTO user (user, pass, block_remaining, active_until, product_id, time_added)
+  VALUES ('herp', 'derp', 100, '2030-01-01', 1, 0);
+INSERT INTO nas (name, cidr, secret, require_ma) VALUES ('local', '127.0.0.1/32', 'secret', 1);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, func() {
+		s.DB.Close()
+		os.RemoveAll(dir)
+	}
+}
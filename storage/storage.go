@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/reaper"
+	"github.com/mpdroog/radiusd/sync"
+)
+
+// Everything main needs from a backend
+type Storage interface {
+	model.Storage
+	sync.Storage
+	reaper.Storage
+}
+
+// Connect to the backend selected by driver (mysql, postgres or sqlite)
+func Open(driver string, dsn string) (Storage, error) {
+	switch driver {
+	case "mysql":
+		s, err := NewMySQL(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Strict(); err != nil {
+			s.DB.Close()
+			return nil, err
+		}
+		return s, nil
+	case "postgres":
+		s, err := NewPostgres(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "sqlite":
+		s, err := NewSQLite(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("storage: unknown driver %s", driver)
+}
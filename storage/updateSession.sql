@@ -1,9 +1,11 @@
 UPDATE session SET
-  bytes_in     = bytes_in + ?,
-  bytes_out    = bytes_out + ?,
-  packets_in   = packets_in + ?,
-  packets_out  = packets_out + ?,
-  session_time = ?
+  bytes_in     = ?,
+  bytes_out    = ?,
+  packets_in   = ?,
+  packets_out  = ?,
+  session_time = ?,
+  time_updated = ?
 WHERE user = ?
   AND session_id = ?
-  AND nas_ip = ?
\ No newline at end of file
+  AND nas_ip = ?
+  AND session_time = ?
\ No newline at end of file
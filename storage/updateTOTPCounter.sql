@@ -0,0 +1,5 @@
+-- Only forward so a counter is accepted once, also across nodes
+UPDATE user SET
+  totp_counter = ?
+WHERE user = ?
+  AND (totp_counter IS NULL OR totp_counter < ?)
\ No newline at end of file
@@ -8,22 +8,31 @@ import (
 	"github.com/mpdroog/radiusd/queue"
 )
 
-func save(storage Storage, hostname string, verbose bool, logger *log.Logger) {
+func save(storage Storage, hostname string, quota *Quota, verbose bool, logger *log.Logger) {
 	entries := queue.Flush()
 	if verbose {
 		logger.Printf("sync.flush %d metrics", len(entries))
 	}
+	// Enforced after the flush, a slow NAS must not delay accounting
+	var exhausted []string
 	for user, entry := range entries {
 		if e := SessionAcct(storage, user, time.Now().UTC().Format("2006-01-02 15:04"), entry.InOctet, entry.OutOctet, entry.InPacket, entry.OutPacket, hostname); e != nil {
 			logger.Printf("WARN: Losing statistic data err=" + e.Error())
 		}
-		if e := UpdateRemaining(storage, user, entry.InOctet+entry.OutOctet); e != nil {
+		empty, e := UpdateRemaining(storage, user, entry.Octets())
+		if e != nil {
 			logger.Printf("WARN: Losing statistic data err=" + e.Error())
 		}
+		if empty {
+			exhausted = append(exhausted, user)
+		}
+	}
+	for _, user := range exhausted {
+		quota.enforce(storage, user, hostname, verbose, logger)
 	}
 }
 
-func Loop(storage Storage, hostname string, verbose bool, logger *log.Logger) {
+func Loop(storage Storage, hostname string, quota *Quota, verbose bool, logger *log.Logger) {
 	rand.Seed(time.Now().Unix())
 	rnd := time.Duration(rand.Int31n(20)) * time.Second
 	sleep := time.Duration(time.Minute + rnd)
@@ -32,11 +41,11 @@ func Loop(storage Storage, hostname string, verbose bool, logger *log.Logger) {
 	}
 
 	for range time.Tick(sleep) {
-		save(storage, hostname, verbose, logger)
+		save(storage, hostname, quota, verbose, logger)
 	}
 }
 
 // Force writing stats now
-func Force(storage Storage, hostname string, verbose bool, logger *log.Logger) {
-	save(storage, hostname, verbose, logger)
+func Force(storage Storage, hostname string, quota *Quota, verbose bool, logger *log.Logger) {
+	save(storage, hostname, quota, verbose, logger)
 }
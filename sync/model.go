@@ -1,47 +1,64 @@
 package sync
 
 import (
+	"math"
+
 	"github.com/pkg/errors"
 )
 
+// Counters are stored as signed BIGINT, clamp instead of going negative
+func signed(n uint64) int64 {
+	if n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
+
 func SessionAcct(
 	storage Storage,
 	user string,
 	date string,
-	octetIn uint32,
-	octetOut uint32,
-	packetIn uint32,
-	packetOut uint32,
+	octetIn uint64,
+	octetOut uint64,
+	packetIn uint64,
+	packetOut uint64,
 	hostname string,
 ) error {
 	return storage.InsertAcct(
 		user,
 		date,
-		int(octetIn),
-		int(octetOut),
-		int(packetIn),
-		int(packetOut),
+		signed(octetIn),
+		signed(octetOut),
+		signed(packetIn),
+		signed(packetOut),
 		hostname,
 	)
 }
 
-func UpdateRemaining(storage Storage, user string, remain uint32) error {
+// Subtract usage from block_remaining, exhausted is true if
+// this update brought it down to zero.
+func UpdateRemaining(storage Storage, user string, remain uint64) (exhausted bool, err error) {
 	if remain == 0 {
-		return nil
+		return false, nil
 	}
 
-	err := storage.UpdateUsage(user, int(remain))
+	err = storage.UpdateUsage(user, signed(remain))
 	if errors.Cause(err) == ErrUpdateUsage {
 		// Nothing changed, check if this behaviour is correct
 		remain, e := checkRemain(storage, user)
 		if e != nil {
-			return e
+			return false, e
 		}
 		if !remain {
-			return errors.Wrapf(ErrUpdateUsage, "user=%s", user)
+			return false, errors.Wrapf(ErrUpdateUsage, "user=%s", user)
 		}
+		// Already zero (or no block), enforced earlier
+		return false, nil
+	}
+	if err != nil {
+		return false, err
 	}
-	return nil
+	return checkRemain(storage, user)
 }
 
 func checkRemain(storage Storage, user string) (bool, error) {
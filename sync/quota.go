@@ -0,0 +1,67 @@
+package sync
+
+import (
+	"log"
+	"time"
+
+	"github.com/mpdroog/radiusd/dynauth"
+	"github.com/mpdroog/radiusd/radius"
+	"github.com/mpdroog/radiusd/radius/vendor"
+)
+
+const (
+	QuotaDisconnect = "disconnect"
+	QuotaThrottle   = "coa"
+)
+
+// What to do with open sessions of users whose block_remaining
+// reached zero during a flush
+type Quota struct {
+	Action    string // QuotaDisconnect, QuotaThrottle or "" for nothing
+	RateLimit string // QuotaThrottle: MikrotikRateLimit, i.e. 64k/64k
+	Port      int
+	Timeout   time.Duration
+	Secret    func(nasIP string) string // Dynauth secret for NAS
+}
+
+// Disconnect or throttle all sessions of user, every attempt is
+// written to the quota_action table.
+func (q *Quota) enforce(storage Storage, user string, hostname string, verbose bool, logger *log.Logger) {
+	if q == nil || q.Action == "" {
+		return
+	}
+	sessions, e := storage.SelectSessions(user)
+	if e != nil {
+		logger.Printf("WARN: quota.enforce user=%s e=%s", user, e.Error())
+		return
+	}
+	if verbose {
+		logger.Printf("quota.enforce user=%s action=%s sessions=%d", user, q.Action, len(sessions))
+	}
+
+	for _, sess := range sessions {
+		secret := q.Secret(sess.NasIP)
+		if q.Action == QuotaThrottle {
+			attrs := []radius.AttrEncoder{radius.VendorAttr{
+				Type:     radius.VendorSpecific,
+				VendorId: vendor.Mikrotik,
+				Values: []radius.VendorAttrString{radius.VendorAttrString{
+					Type:  vendor.MikrotikRateLimit,
+					Value: []byte(q.RateLimit),
+				}},
+			}.Encode()}
+			e = dynauth.CoA(q.Port, secret, sess, attrs, q.Timeout, verbose, logger)
+		} else {
+			e = dynauth.Disconnect(q.Port, secret, sess, q.Timeout, verbose, logger)
+		}
+
+		msg := ""
+		if e != nil {
+			msg = e.Error()
+			logger.Printf("WARN: quota.enforce user=%s e=%s", user, msg)
+		}
+		if e := storage.InsertQuotaAction(user, sess.SessionID, sess.NasIP, q.Action, msg, hostname); e != nil {
+			logger.Printf("WARN: quota.log user=%s e=%s", user, e.Error())
+		}
+	}
+}
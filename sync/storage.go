@@ -1,14 +1,22 @@
 package sync
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/mpdroog/radiusd/model"
+)
 
 var (
 	ErrInsertAcct  = errors.New("account.add fail")
 	ErrUpdateUsage = errors.New("user.update fail")
+
+	ErrInsertQuotaAction = errors.New("quota_action.add fail")
 )
 
 type Storage interface {
-	InsertAcct(name string, date string, rx int, tx int, rxPackets int, txPackets int, hostname string) error
-	UpdateUsage(name string, remain int) error
+	InsertAcct(name string, date string, rx int64, tx int64, rxPackets int64, txPackets int64, hostname string) error
+	UpdateUsage(name string, remain int64) error
 	SelectRemain(name string) (remain int64, err error)
+	SelectSessions(name string) ([]model.Session, error)
+	InsertQuotaAction(name string, sessID string, nasIP string, action string, errMsg string, hostname string) error
 }
@@ -0,0 +1,65 @@
+package sync_test
+
+import (
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mpdroog/radiusd/model"
+	"github.com/mpdroog/radiusd/queue"
+	"github.com/mpdroog/radiusd/storage"
+	"github.com/mpdroog/radiusd/sync"
+)
+
+func TestUpdateRemaining(t *testing.T) {
+	store := storage.NewMemory()
+	block := int64(100)
+	store.AddUser("herp", model.User{BlockRemain: &block})
+
+	for i, expect := range []bool{false, true, false} {
+		exhausted, e := sync.UpdateRemaining(store, "herp", 60)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if exhausted != expect {
+			t.Fatalf("Update %d: expected exhausted=%t", i, expect)
+		}
+	}
+}
+
+func TestQuotaEnforce(t *testing.T) {
+	// Nothing listening, Disconnect-Request times out
+	conn, e := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if e != nil {
+		t.Fatal(e)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	store := storage.NewMemory()
+	block := int64(10)
+	store.AddUser("herp", model.User{BlockRemain: &block})
+	if e := store.CreateSession("herp", "sess1", "127.0.0.1", "10.0.0.2", "1.2.3.4"); e != nil {
+		t.Fatal(e)
+	}
+
+	queue.Queue("herp", 20, 0, 1, 0)
+	quota := &sync.Quota{
+		Action:  sync.QuotaDisconnect,
+		Port:    port,
+		Timeout: 50 * time.Millisecond,
+		Secret:  func(nasIP string) string { return "secret" },
+	}
+	sync.Force(store, "test", quota, false, log.New(ioutil.Discard, "", 0))
+
+	actions := store.QuotaActions()
+	if len(actions) != 1 {
+		t.Fatalf("Expected 1 quota_action, found=%d", len(actions))
+	}
+	a := actions[0]
+	if a.User != "herp" || a.SessionID != "sess1" || a.Action != sync.QuotaDisconnect || a.Error == "" || a.Hostname != "test" {
+		t.Fatalf("Unexpected quota_action %+v", a)
+	}
+}
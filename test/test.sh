@@ -11,6 +11,10 @@ cat acct-update.txt | radclient 127.0.0.1 auto secret -x
 cat acct-stop.txt | radclient 127.0.0.1 auto secret -x
 cat auth.txt | radclient 127.0.0.1 auto secret -x # 0 conns remain
 
+cat acct-start.txt | radclient 127.0.0.1 auto secret -x
+cat acct-on.txt | radclient 127.0.0.1 auto secret -x # NAS reboot clears the session
+cat auth.txt | radclient 127.0.0.1 auto secret -x # 0 conns remain
+
 cat auth-chap.txt | radclient 127.0.0.1 auto secret -x -i 217 # CHAP-auth (md5)
 cat auth-mschapv1.txt | radclient -x 127.0.0.1 auto secret # MS-CHAPv1
 cat auth-mschapv2.txt | radclient -x 127.0.0.1 auto secret # MS-CHAPv2
@@ -0,0 +1,81 @@
+// Time-based one-time passwords (Google Authenticator and alike)
+// https://tools.ietf.org/html/rfc6238
+// https://tools.ietf.org/html/rfc4226
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	Step   = 30 * time.Second
+	Digits = 6
+	Skew   = 1 // Steps accepted before/after now for clock drift
+)
+
+// Base32 secret as shown in authenticator apps
+func Decode(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	secret = strings.TrimRight(secret, "=")
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+}
+
+// HOTP value for counter
+func hotp(key []byte, counter uint64, digits int) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	h := hmac.New(sha1.New, key)
+	h.Write(msg)
+	sum := h.Sum(nil)
+
+	// Dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// Counter for t
+func Counter(t time.Time) uint64 {
+	return uint64(t.Unix() / int64(Step/time.Second))
+}
+
+// Code for secret at t
+func Code(secret string, t time.Time) (string, error) {
+	key, e := Decode(secret)
+	if e != nil {
+		return "", e
+	}
+	return hotp(key, Counter(t), Digits), nil
+}
+
+// Counter matching code within Skew steps of t
+func Validate(secret string, code string, t time.Time) (uint64, bool, error) {
+	key, e := Decode(secret)
+	if e != nil {
+		return 0, false, e
+	}
+	if len(code) != Digits {
+		return 0, false, nil
+	}
+
+	now := Counter(t)
+	for i := -Skew; i <= Skew; i++ {
+		counter := uint64(int64(now) + int64(i))
+		if subtle.ConstantTimeCompare([]byte(hotp(key, counter, Digits)), []byte(code)) == 1 {
+			return counter, true, nil
+		}
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,45 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// RFC6238 Appendix B (SHA1)
+func TestVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	tests := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, expect := range tests {
+		if code := hotp(key, Counter(time.Unix(unix, 0)), 8); code != expect {
+			t.Errorf("T=%d expected=%s found=%s", unix, expect, code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111109, 0)
+
+	code, e := Code(secret, now.Add(-Step))
+	if e != nil {
+		t.Fatal(e)
+	}
+	counter, ok, e := Validate(secret, code, now)
+	if e != nil || !ok || counter != Counter(now)-1 {
+		t.Fatalf("Expected previous step to be accepted, ok=%t e=%v", ok, e)
+	}
+	if _, ok, _ := Validate(secret, code, now.Add(2*Step)); ok {
+		t.Fatal("Expected code outside skew to be rejected")
+	}
+	if _, ok, _ := Validate(secret, "12345", now); ok {
+		t.Fatal("Expected short code to be rejected")
+	}
+}